@@ -0,0 +1,58 @@
+// SPDX-License-Identifier: GPL-3.0-or-later
+package main
+
+// expandBraces expands each {a,b} group in pattern, as a shell does, eg: test/{plex,wsdd}.json is test/plex.json and
+// test/wsdd.json, for when the shell hasn't already, say as the FILE was quoted. Groups may be nested. A brace escaped
+// with a backslash, or unmatched, or a group without a comma, eg: {x}, is left as it is, as for the shell.
+func expandBraces(pattern string) []string {
+	start, end, commas := findBraces(pattern)
+	if start < 0 {
+		return []string{pattern}
+	}
+	var expanded []string
+	from := start + 1
+	for _, comma := range append(commas, end) {
+		expanded = append(expanded, expandBraces(pattern[:start]+pattern[from:comma]+pattern[end+1:])...)
+		from = comma + 1
+	}
+	return expanded
+}
+
+// findBraces returns the positions of the first brace group in pattern with at least one comma, and of its commas, not
+// counting those of any nested groups, or -1 if there's none.
+func findBraces(pattern string) (start, end int, commas []int) {
+	for start = 0; start < len(pattern); start++ {
+		switch pattern[start] {
+		case '\\':
+			start++ // Escaped, so skip what follows, which may be a brace
+			continue
+		case '{':
+		default:
+			continue
+		}
+		depth := 0
+		commas = nil
+	group:
+		for end = start + 1; end < len(pattern); end++ {
+			switch pattern[end] {
+			case '\\':
+				end++
+			case '{':
+				depth++
+			case ',':
+				if depth == 0 {
+					commas = append(commas, end)
+				}
+			case '}':
+				if depth == 0 {
+					break group
+				}
+				depth--
+			}
+		}
+		if end < len(pattern) && len(commas) > 0 {
+			return start, end, commas
+		}
+	}
+	return -1, -1, nil
+}
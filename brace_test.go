@@ -0,0 +1,74 @@
+// SPDX-License-Identifier: GPL-3.0-or-later
+package main
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestExpandBraces(t *testing.T) {
+	tests := []struct {
+		name, pattern string
+		want          []string
+	}{
+		{"no braces", "plex.json", []string{"plex.json"}},
+		{"group", "{a,b}.json", []string{"a.json", "b.json"}},
+		{"group in a path", "test/{plex,wsdd}.json", []string{"test/plex.json", "test/wsdd.json"}},
+		{"nested group", "{a,{b,c}}", []string{"a", "b", "c"}},
+		{"nested group with a prefix", "{a,x{b,c}}", []string{"a", "xb", "xc"}},
+		{"groups", "{a,b}{c,d}", []string{"ac", "ad", "bc", "bd"}},
+		{"empty alternative", "plex{,-lsio}.json", []string{"plex.json", "plex-lsio.json"}},
+		{"escaped brace", `\{a,b}`, []string{`\{a,b}`}},
+		{"escaped comma", `{a\,b}`, []string{`{a\,b}`}},
+		{"escaped brace in a group", `{a,\}}`, []string{"a", `\}`}},
+		{"unmatched {", "{a,b", []string{"{a,b"}},
+		{"unmatched { before a group", "{x{a,b}", []string{"{xa", "{xb"}},
+		{"unmatched }", "a,b}", []string{"a,b}"}},
+		{"no comma", "{x}", []string{"{x}"}},
+		{"empty", "{}", []string{"{}"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := expandBraces(tt.pattern); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expandBraces(%q) = %q, want %q", tt.pattern, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseFileArgs(t *testing.T) {
+	if pattern := os.Getenv("PARSE_FILE_ARGS"); pattern != "" {
+		parseFileArgs() // The pattern is the argument after --, so exits if it matches no files
+		return
+	}
+	dir := writeFiles(t, map[string]string{"plex.json": plexJSON})
+	tests := []struct {
+		name, pattern string
+		code          int
+	}{
+		{"match", "plex.json", exitOK},
+		{"match of a group", "{plex,plex}.json", exitOK},
+		{"no match", "wsdd.json", exitUsage},
+		{"no match of a group", "{plex,wsdd}.json", exitUsage}, // One alternative matching doesn't make up for another
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := exec.Command(os.Args[0], "-test.run=^TestParseFileArgs$", "--", filepath.Join(dir, tt.pattern))
+			cmd.Env = append(os.Environ(), "PARSE_FILE_ARGS="+tt.pattern)
+			code := exitOK
+			var exitErr *exec.ExitError
+			if err := cmd.Run(); errors.As(err, &exitErr) {
+				code = exitErr.ExitCode()
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if code != tt.code {
+				t.Errorf("parseFileArgs() with %s exit code = %d, want %d", tt.pattern, code, tt.code)
+			}
+		})
+	}
+}
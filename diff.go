@@ -0,0 +1,126 @@
+// SPDX-License-Identifier: GPL-3.0-or-later
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/hexops/gotextdiff"
+	"github.com/hexops/gotextdiff/myers"
+	"github.com/hexops/gotextdiff/span"
+)
+
+// unifiedDiff returns the unified diff between before and after, of file f, with context lines of context around each
+// change.
+func unifiedDiff(f, before, after string, context int) string {
+	aPath := "a/" + strings.TrimPrefix(f, "/")
+	bPath := "b/" + strings.TrimPrefix(f, "/")
+	edits := myers.ComputeEdits(span.URIFromPath(aPath), before, after)
+	return formatUnified(toUnified(aPath, bPath, before, edits, context))
+}
+
+// createdDiff returns the unified diff creating file f with content after, from /dev/null as git apply expects.
+func createdDiff(f, after string) string {
+	bPath := "b/" + strings.TrimPrefix(f, "/")
+	edits := myers.ComputeEdits(span.URIFromPath(bPath), "", after)
+	return formatUnified(toUnified("/dev/null", bPath, "", edits, 0))
+}
+
+// formatUnified is the format of gotextdiff.Unified, but with a hunk that removes or adds no lines at all numbered
+// from the line before it, eg: -0,0 for a new file, as patch and git apply expect.
+func formatUnified(u gotextdiff.Unified) string {
+	if len(u.Hunks) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "--- %s\n+++ %s\n", u.From, u.To)
+	for _, h := range u.Hunks {
+		fromCount, toCount := 0, 0
+		for _, l := range h.Lines {
+			if l.Kind != gotextdiff.Insert {
+				fromCount++
+			}
+			if l.Kind != gotextdiff.Delete {
+				toCount++
+			}
+		}
+		fmt.Fprintf(&b, "@@ %s %s @@\n", hunkRange('-', h.FromLine, fromCount), hunkRange('+', h.ToLine, toCount))
+		for _, l := range h.Lines {
+			switch l.Kind {
+			case gotextdiff.Delete:
+				b.WriteString("-" + l.Content)
+			case gotextdiff.Insert:
+				b.WriteString("+" + l.Content)
+			default:
+				b.WriteString(" " + l.Content)
+			}
+			if !strings.HasSuffix(l.Content, "\n") {
+				b.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+	}
+	return b.String()
+}
+
+// hunkRange returns the range of a hunk header, eg: -3,7, leaving out a count of 1.
+func hunkRange(sign rune, line, count int) string {
+	switch count {
+	case 0:
+		return fmt.Sprintf("%c%d,0", sign, line-1)
+	case 1:
+		return fmt.Sprintf("%c%d", sign, line)
+	}
+	return fmt.Sprintf("%c%d,%d", sign, line, count)
+}
+
+// writeDiffOutput writes the diffs of every changed file in results, followed by indexDiffs, those of any root.json, to
+// the --diff-output as a single patch, eg: for git apply.
+func writeDiffOutput(results []*fileResult, indexDiffs []string) error {
+	var b strings.Builder
+	for _, res := range results {
+		b.WriteString(res.patch)
+	}
+	for _, diff := range indexDiffs {
+		b.WriteString(diff)
+	}
+	return os.WriteFile(diffOutputFlag, []byte(b.String()), 0o644)
+}
+
+// diffStat returns the number of lines inserted and deleted between before and after, as in git diff --stat.
+func diffStat(before, after string) (insertions, deletions int) {
+	edits := myers.ComputeEdits(span.URIFromPath("a"), before, after)
+	for _, h := range toUnified("a", "b", before, edits, 0).Hunks {
+		for _, line := range h.Lines {
+			switch line.Kind {
+			case gotextdiff.Insert:
+				insertions++
+			case gotextdiff.Delete:
+				deletions++
+			}
+		}
+	}
+	return insertions, deletions
+}
+
+// printDiffStat prints the insertions and deletions of each changed file in results, followed by their total, as in
+// git diff --stat.
+func printDiffStat(results []*fileResult) {
+	width := 0
+	for _, res := range results {
+		if res.Changed && len(res.out) > width {
+			width = len(res.out)
+		}
+	}
+	var files, insertions, deletions int
+	for _, res := range results {
+		if !res.Changed {
+			continue
+		}
+		fmt.Printf(" %-*s | +%d -%d\n", width, res.out, res.insertions, res.deletions)
+		files++
+		insertions += res.insertions
+		deletions += res.deletions
+	}
+	fmt.Printf(" %d files changed, %d insertions(+), %d deletions(-)\n", files, insertions, deletions)
+}
@@ -0,0 +1,57 @@
+// SPDX-License-Identifier: GPL-3.0-or-later
+package main
+
+import "encoding/json"
+
+// droppedFields returns the path of each field of the JSON before that is missing from after, eg: Plex.webiste, so
+// that --write never silently loses anything that isn't part of a rockon. Empty fields are left out, as the correct
+// format omits many of them, eg: "tag": "".
+func droppedFields(before, after []byte) (dropped []string) {
+	var b, a any
+	if json.Unmarshal(before, &b) != nil || json.Unmarshal(after, &a) != nil {
+		return nil
+	}
+	var walk func(path string, b, a any)
+	walk = func(path string, b, a any) {
+		bMap, ok := b.(map[string]any)
+		if !ok {
+			return // Only the fields of objects can be dropped, the elements of arrays are kept as they are
+		}
+		aMap, _ := a.(map[string]any)
+		for _, k := range sortedKeys(bMap) {
+			p := k
+			if path != "" {
+				p = path + "." + k
+			}
+			if v, found := aMap[k]; found {
+				walk(p, bMap[k], v)
+			} else if !isEmpty(bMap[k]) {
+				dropped = append(dropped, p)
+			}
+		}
+	}
+	walk("", b, a)
+	return dropped
+}
+
+// isEmpty reports whether v is the zero value of its JSON type, eg: "", 0, [] or null, or an object of only those.
+func isEmpty(v any) bool {
+	switch v := v.(type) {
+	case string:
+		return v == ""
+	case float64:
+		return v == 0
+	case bool:
+		return !v
+	case []any:
+		return len(v) == 0
+	case map[string]any:
+		for _, field := range v {
+			if !isEmpty(field) {
+				return false
+			}
+		}
+		return true
+	}
+	return v == nil
+}
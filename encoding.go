@@ -0,0 +1,32 @@
+// SPDX-License-Identifier: GPL-3.0-or-later
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"unicode/utf8"
+
+	"golang.org/x/exp/slog" // nee "log/slog"
+)
+
+var utf8BOM = []byte{0xef, 0xbb, 0xbf}
+
+// checkEncoding returns data without any leading UTF-8 byte order mark, which JSON does not allow, so that it's left
+// out of the correctly formatted rockon. It reports false if the rest is not valid UTF-8, eg: Latin-1 from an old
+// editor, which would otherwise be silently mangled into U+FFFD.
+func checkEncoding(logger *slog.Logger, f string, data []byte) ([]byte, bool) {
+	if bytes.HasPrefix(data, utf8BOM) {
+		logger.Warn("File starts with a UTF-8 byte order mark, which --write removes", slog.String("file", f), rule(ruleByteOrderMark))
+		data = data[len(utf8BOM):]
+	}
+	for i := 0; i < len(data); {
+		r, size := utf8.DecodeRune(data[i:])
+		if r == utf8.RuneError && size == 1 {
+			line := bytes.Count(data[:i], []byte("\n")) + 1
+			logger.Error("File is not valid UTF-8, it may be in another encoding, eg: Latin-1", slog.String("file", f), slog.Int("line", line), slog.String("byte", fmt.Sprintf("%#x", data[i])), rule(ruleInvalidUTF8))
+			return data, false
+		}
+		i += size
+	}
+	return data, true
+}
@@ -0,0 +1,37 @@
+// SPDX-License-Identifier: GPL-3.0-or-later
+package main
+
+import (
+	"fmt"
+)
+
+// Exit codes, so that scripts can tell why a run failed.
+const (
+	exitOK          = 0 // Everything checked out
+	exitCheckFailed = 1 // --check found a file that is not correctly formatted, or has errors. Or --count-only or --check-format one of the former, or --fail-on-warning a warning.
+	exitUsage       = 2 // The flags or FILE(s) were invalid, eg: a FILE matched no files. As with the flag package.
+	exitBadFile     = 3 // A file could not be read, or was named .json but could not be parsed as a rockon
+	exitIndex       = 4 // --check found errors in a root.json, or --check-index-sorted one not correctly formatted
+	exitWrite       = 5 // A file could not be written, or --write would have dropped fields from it
+	exitInternal    = 6 // A rockon could not be marshalled back to JSON, or --selftest found the formatting unstable. Never!
+)
+
+var exitCodes = []struct {
+	code    int
+	meaning string
+}{
+	{exitOK, "Success"},
+	{exitCheckFailed, "--check found a file that is not correctly formatted, or has errors. Or --count-only or --check-format found one that is not correctly formatted, or --fail-on-warning found a warning"},
+	{exitUsage, "Invalid flags or FILE(s), eg: a FILE matched no files"},
+	{exitBadFile, "A file could not be read, or was named .json but could not be parsed as a rockon"},
+	{exitIndex, "--check found errors in a root.json, or --check-index-sorted found one that is not correctly formatted"},
+	{exitWrite, "A file could not be written, or --write would have dropped fields that are not part of a rockon"},
+	{exitInternal, "A rockon could not be marshalled back to JSON, or --selftest found formatting it twice differs"},
+}
+
+// explainExit prints what each exit code means.
+func explainExit() {
+	for _, e := range exitCodes {
+		fmt.Printf("%d    %s\n", e.code, e.meaning)
+	}
+}
@@ -0,0 +1,48 @@
+// SPDX-License-Identifier: GPL-3.0-or-later
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/exp/maps"
+	"golang.org/x/exp/slices"
+	"golang.org/x/exp/slog" // nee "log/slog"
+)
+
+// changedFiles returns the rockons that git reports as changed in the working tree, staged or not, along with any
+// new ones that are yet to be added. Only those under pathspecs are returned, if any are given. Outside of a git
+// repository, it exits rather than checking nothing.
+func changedFiles(pathspecs []string) []string {
+	out, err := exec.Command("git", "rev-parse", "--is-inside-work-tree").Output()
+	if err != nil || strings.TrimSpace(string(out)) != "true" {
+		logger.Error("--changed-only must be run inside a git repository", slog.Any("err", err))
+		os.Exit(exitUsage)
+	}
+
+	changed := map[string]bool{}
+	for _, args := range [][]string{
+		{"diff", "--name-only", "--relative", "--diff-filter=d"},
+		{"diff", "--name-only", "--relative", "--diff-filter=d", "--cached"},
+		{"ls-files", "--others", "--exclude-standard"},
+	} {
+		args = append(append(args, "--"), pathspecs...)
+		out, err := exec.Command("git", args...).Output()
+		if err != nil {
+			logger.Error("Running git", slog.Any("args", args), slog.Any("err", err))
+			os.Exit(exitUsage)
+		}
+		for _, f := range strings.Split(string(out), "\n") {
+			if (filepath.Ext(f) == ".json" || isYAML(f)) && filepath.Base(f) != "root.json" {
+				changed[f] = true
+			}
+		}
+	}
+
+	files := maps.Keys(changed)
+	slices.Sort(files)
+	logger.Debug("Changed files", slog.Any("files", files))
+	return files
+}
@@ -6,4 +6,8 @@ require (
 	github.com/hexops/gotextdiff v1.0.3
 	github.com/lmittmann/tint v0.3.4
 	golang.org/x/exp v0.0.0-20230713183714-613f0c0eb8a1
+	golang.org/x/net v0.12.0
+	sigs.k8s.io/yaml v1.3.0
 )
+
+require gopkg.in/yaml.v2 v2.4.0 // indirect
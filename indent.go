@@ -0,0 +1,41 @@
+// SPDX-License-Identifier: GPL-3.0-or-later
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+)
+
+const canonicalIndent = "    " // As model.RockOn.ToJSON indents
+
+// detectIndent returns the indent of the first indented line of data, eg: two spaces or a tab, or the canonical
+// indent if there is none, eg: the file is all on one line.
+func detectIndent(data string) string {
+	for _, line := range strings.Split(data, "\n") {
+		trimmed := strings.TrimLeft(line, " \t")
+		if indent := line[:len(line)-len(trimmed)]; indent != "" && trimmed != "" {
+			return indent
+		}
+	}
+	return canonicalIndent
+}
+
+// reindent returns the correctly formatted rockon s indented by indent rather than the canonical four spaces, for
+// --sort-keys-only. No indent at all compacts it onto a single line, for --compact.
+func reindent(s, indent string) string {
+	if indent == canonicalIndent {
+		return s
+	}
+	var buf bytes.Buffer
+	if indent == "" {
+		if err := json.Compact(&buf, []byte(s)); err != nil { // Unlike json.Marshal, this leaves any HTML unescaped
+			return s
+		}
+		return buf.String() + "\n"
+	}
+	if err := json.Indent(&buf, []byte(s), "", indent); err != nil {
+		return s // s was marshalled by us, so this shouldn't happen
+	}
+	return buf.String()
+}
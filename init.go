@@ -0,0 +1,79 @@
+// SPDX-License-Identifier: GPL-3.0-or-later
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/exp/slog" // nee "log/slog"
+
+	"github.com/rockstor/rockon-validator/model"
+)
+
+// initRockon writes a skeleton rockon called name to name.json, lowercased, in the directory of root.json, and adds it
+// to root.json, returning the exit code. The fields left empty are for the author to fill in, and are warned about when the file is checked.
+// An existing rockon is only overwritten under --write, and nothing is written if root.json exists but can't be parsed.
+func initRockon(name string) int {
+	rootFile := rootFlag
+	if rootFile == "" {
+		rootFile = "root.json"
+	}
+	entry := strings.ToLower(name) + ".json"
+	f := filepath.Join(filepath.Dir(rootFile), entry) // As root.json entries are relative to it
+	if _, err := os.Stat(f); err == nil && !writeFlag {
+		logger.Error("Rockon already exists, pass --write to overwrite it", slog.String("file", f))
+		return exitUsage
+	} else if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		logger.Error("Reading file", slog.String("file", f), slog.Any("err", err))
+		return exitBadFile
+	}
+
+	mode := fs.FileMode(0o644)
+	if stat, err := os.Stat(rootFile); err == nil {
+		mode = stat.Mode()
+	}
+	entries := map[string]string{}
+	rootData, err := os.ReadFile(rootFile)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		logger.Error("Reading root", slog.String("file", rootFile), slog.Any("err", err))
+		return exitBadFile
+	}
+	if err == nil {
+		// Checked before writing anything, as rewriting a root.json that can't be parsed would lose its entries
+		if err := json.Unmarshal(rootData, &entries); err != nil {
+			logger.Error("Parsing root.json, expected an object of names to file names", slog.String("root.json", rootFile), slog.Any("err", err), rule(ruleIndexInvalid))
+			return exitBadFile
+		}
+	}
+
+	rockon := model.RockOn{name: {
+		Containers: map[string]model.Container{
+			strings.ToLower(name): {LaunchOrder: 1, Ports: map[string]model.Port{}},
+		},
+	}}
+	result, err := rockon.ToJSON()
+	if err != nil {
+		logger.Error("Marshaling to JSON", slog.Any("err", err))
+		return exitInternal
+	}
+	logger.Info("Writing rockon", slog.String("file", f))
+	if err := os.WriteFile(f, []byte(result), 0o644); err != nil {
+		logger.Error("Writing rockon", slog.String("file", f), slog.Any("err", err))
+		return exitWrite
+	}
+
+	if existing, found := entries[name]; found && existing != entry {
+		logger.Warn("Replacing root.json entry", slog.String("root.json", rootFile), slog.String("name", name), slog.String("file", existing))
+	}
+	entries[name] = entry
+	logger.Info("Writing root", slog.String("file", rootFile))
+	if err := os.WriteFile(rootFile, formatIndex(entries), mode); err != nil {
+		logger.Error("Writing root", slog.String("file", rootFile), slog.Any("err", err))
+		return exitWrite
+	}
+	return exitOK
+}
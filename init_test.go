@@ -0,0 +1,77 @@
+// SPDX-License-Identifier: GPL-3.0-or-later
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// chdir changes to dir for the rest of the test.
+func chdir(t *testing.T, dir string) {
+	t.Helper()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+}
+
+func TestInitRockon(t *testing.T) {
+	tests := []struct {
+		name     string
+		rootDir  string // The directory of root.json, passed as --root unless it's the current one
+		root     string // The existing root.json, if any
+		code     int
+		wantRoot string // The root.json afterwards
+		written  bool   // Whether foo.json is written, beside root.json
+	}{
+		{"no root.json", "", "", exitOK, "{\n    \"Foo\": \"foo.json\"\n}\n", true},
+		{"existing root.json", "", `{"Bar": "bar.json"}`, exitOK, "{\n    \"Bar\": \"bar.json\",\n    \"Foo\": \"foo.json\"\n}\n", true},
+		{"corrupt root.json", "", `{"a": "a.json",`, exitBadFile, `{"a": "a.json",`, false},
+		{"root.json in another directory", "rockons", `{"Bar": "bar.json"}`, exitOK, "{\n    \"Bar\": \"bar.json\",\n    \"Foo\": \"foo.json\"\n}\n", true},
+		{"no root.json in another directory", "rockons", "", exitOK, "{\n    \"Foo\": \"foo.json\"\n}\n", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chdir(t, t.TempDir())
+			rootFlag, indexFormatFlag = "", "4space"
+			t.Cleanup(func() { rootFlag = "" })
+			dir := "."
+			if tt.rootDir != "" {
+				dir = tt.rootDir
+				rootFlag = filepath.Join(dir, "root.json")
+				if err := os.Mkdir(dir, 0o755); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tt.root != "" {
+				if err := os.WriteFile(filepath.Join(dir, "root.json"), []byte(tt.root), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if code := initRockon("Foo"); code != tt.code {
+				t.Errorf("initRockon() = %d, want %d", code, tt.code)
+			}
+			root, _ := os.ReadFile(filepath.Join(dir, "root.json"))
+			if string(root) != tt.wantRoot {
+				t.Errorf("root.json = %q, want %q", root, tt.wantRoot)
+			}
+			_, err := os.Stat(filepath.Join(dir, "foo.json"))
+			if written := !errors.Is(err, fs.ErrNotExist); written != tt.written {
+				t.Errorf("%s written = %v, want %v", filepath.Join(dir, "foo.json"), written, tt.written)
+			}
+			if dir != "." {
+				if _, err := os.Stat("foo.json"); !errors.Is(err, fs.ErrNotExist) {
+					t.Errorf("foo.json written to the current directory, rather than beside root.json")
+				}
+			}
+		})
+	}
+}
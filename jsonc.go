@@ -0,0 +1,41 @@
+// SPDX-License-Identifier: GPL-3.0-or-later
+package main
+
+// stripComments removes any // line and /* block */ comments from the JSONC in data, leaving plain JSON. Anything
+// that looks like a comment within a string, eg: "https://example.com", is left as it is.
+func stripComments(data []byte) []byte {
+	out := make([]byte, 0, len(data))
+	inString := false
+	for i := 0; i < len(data); i++ {
+		b := data[i]
+		switch {
+		case inString:
+			out = append(out, b)
+			if b == '\\' && i+1 < len(data) {
+				i++
+				out = append(out, data[i]) // The escaped character, which may be a quote
+			} else if b == '"' {
+				inString = false
+			}
+		case b == '"':
+			inString = true
+			out = append(out, b)
+		case b == '/' && i+1 < len(data) && data[i+1] == '/':
+			for i+1 < len(data) && data[i+1] != '\n' {
+				i++
+			}
+		case b == '/' && i+1 < len(data) && data[i+1] == '*':
+			i += 2
+			for i < len(data) && !(data[i] == '*' && i+1 < len(data) && data[i+1] == '/') {
+				if data[i] == '\n' {
+					out = append(out, '\n') // Keep the lines where they were, for any errors
+				}
+				i++
+			}
+			i++ // The closing /, or past the end if the comment was never closed
+		default:
+			out = append(out, b)
+		}
+	}
+	return out
+}
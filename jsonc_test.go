@@ -0,0 +1,57 @@
+// SPDX-License-Identifier: GPL-3.0-or-later
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestStripComments(t *testing.T) {
+	tests := []struct {
+		name, data, want string
+	}{
+		{"no comments", `{"a": 1}`, `{"a": 1}`},
+		{"line comment", "{\"a\": 1} // x\n", "{\"a\": 1} \n"},
+		{"line comment at the end", `{"a": 1} // x`, `{"a": 1} `},
+		{"block comment", `{"a": /* x */ 1}`, `{"a":  1}`},
+		{"block comment over lines", "{\n/* x\ny\n*/ \"a\": 1}", "{\n\n\n \"a\": 1}"},
+		{"// in a string", `{"a": "http://example.com"}`, `{"a": "http://example.com"}`},
+		{"/* */ in a string", `{"a": "/* x */"}`, `{"a": "/* x */"}`},
+		{"escaped quote in a string", `{"a": "\" // x"} // y`, `{"a": "\" // x"} `},
+		{"escaped backslash ending a string", `{"a": "\\"} // x`, `{"a": "\\"} `},
+		{"unterminated block comment", "{\"a\": 1} /* x\ny", "{\"a\": 1} \n"},
+		{"unterminated string", `{"a": "// x`, `{"a": "// x`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(stripComments([]byte(tt.data))); got != tt.want {
+				t.Errorf("stripComments(%q) = %q, want %q", tt.data, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStripCommentsKeepsErrorLine(t *testing.T) {
+	tests := []struct {
+		name, data string
+		line       int
+	}{
+		{"after a line comment", "{\n  // x\n  \"a\": 1,\n  \"b\": ,\n}", 4},
+		{"after a block comment", "{\n  /* x\n  y */\n  \"a\": 1,\n  \"b\": ,\n}", 5},
+		{"in an unterminated block comment", "{\n  \"a\": 1\n  /* x\n  y\n", 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := stripComments([]byte(tt.data))
+			var syntaxErr *json.SyntaxError
+			if err := json.Unmarshal(data, &map[string]any{}); !errors.As(err, &syntaxErr) {
+				t.Fatalf("json.Unmarshal(%q) err = %v, want a *json.SyntaxError", data, err)
+			}
+			if got := bytes.Count(data[:syntaxErr.Offset], []byte("\n")) + 1; got != tt.line {
+				t.Errorf("error on line %d, want %d", got, tt.line)
+			}
+		})
+	}
+}
@@ -0,0 +1,69 @@
+// SPDX-License-Identifier: GPL-3.0-or-later
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+
+	"golang.org/x/exp/slog" // nee "log/slog"
+)
+
+type junitTestSuite struct {
+	XMLName   xml.Name        `xml:"testsuite"`
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	TestCases []junitTestCase `xml:"testcase"`
+	SystemOut string          `xml:"system-out,omitempty"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// printJUnit prints the results as a JUnit XML test suite.
+func printJUnit(sum summary) {
+	out, _ := xml.MarshalIndent(junitSuite(sum), "", "    ")
+	fmt.Println(xml.Header + string(out))
+}
+
+// junitSuite returns the results as a test suite, with a test case for each file checked that fails if the file isn't
+// valid. Errors make up the failure, while warnings are only included as output.
+func junitSuite(sum summary) junitTestSuite {
+	suite := junitTestSuite{Name: "rockon-validator", Tests: sum.Processed, Failures: sum.Processed - sum.Valid}
+	for _, res := range checkedResults() {
+		tc := junitTestCase{Name: res.File, ClassName: "rockon-validator"}
+		var errs, warnings []string
+		if res.Changed {
+			errs = append(errs, "Not correctly formatted, run rockon-validator --write")
+		}
+		for _, p := range res.Problems {
+			if p.Level == slog.LevelError.String() {
+				errs = append(errs, p.text())
+			} else {
+				warnings = append(warnings, p.text())
+			}
+		}
+		if !res.Valid {
+			tc.Failure = &junitFailure{Message: fmt.Sprintf("%d problems", len(errs)), Text: strings.Join(errs, "\n")}
+		}
+		tc.SystemOut = strings.Join(warnings, "\n")
+		suite.TestCases = append(suite.TestCases, tc)
+	}
+
+	var runOut []string
+	for _, p := range runProblems {
+		runOut = append(runOut, p.Level+" "+p.text())
+	}
+	suite.SystemOut = strings.Join(runOut, "\n")
+	return suite
+}
@@ -3,96 +3,613 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
+	"golang.org/x/exp/maps"
+	"golang.org/x/exp/slices"
 	"golang.org/x/exp/slog" // nee "log/slog"
 
-	"github.com/hexops/gotextdiff"
-	"github.com/hexops/gotextdiff/myers"
-	"github.com/hexops/gotextdiff/span"
 	"github.com/lmittmann/tint"
+	"sigs.k8s.io/yaml"
 
 	"github.com/rockstor/rockon-validator/model"
+	"github.com/rockstor/rockon-validator/validator"
 )
 
 const usage = `Usage:
-    rockon-validator [--check] [--diff] [--write] [--root FILE] [--verbose|--debug] FILE...
+    rockon-validator [--check] [--diff] [--write] [--root FILE] [--verbose|--debug|--quiet] FILE...
+    rockon-validator [--check] [--diff] [--write] [--root FILE --name NAME] [--verbose|--debug|--quiet] --stdin
+    rockon-validator [--check] [--write] --root FILE [--verbose|--debug|--quiet]
+    rockon-validator --schema
+    rockon-validator --explain-exit
+    rockon-validator --version
 
 Options:
     -c, --check    Check the FILE(s) for the correct syntax and return non-zero if invalid.
+    --check-format Check only that the FILE(s) are correctly formatted, returning non-zero if reformatting would
+                   change any, whatever errors they have. --check fails on either.
     -d, --diff     Check the FILE(s) for the correct syntax and output a diff if different.
+    --diff-context Number of unchanged lines to show around each change in a diff.
+                   Default: 3
+    --diff-stat    Check the FILE(s) and print how many lines would change in each, as git diff --stat does, for an
+                   overview rather than the whole diff.
+    --diff-output  Check the FILE(s) and write the diffs of all of them, and of root.json, to this file as a single
+                   patch, which git apply accepts, eg: to open a pull request with the fixes.
     -w, --write    Check the FILE(s) and write any changes back to disk in-place.
+    --write-index-only
+                   Check the FILE(s) and write only the root.json, leaving the FILE(s) untouched.
+    --out-dir      Directory to write to, rather than in-place, keeping the file names. It's created if need be.
+                   Implies --write, unless --write-index-only is passed.
+    --selftest     Check the FILE(s), and that formatting each a second time leaves it unchanged, returning 6 if
+                   not, as it would otherwise change on every --write.
+    --count-only   Check the FILE(s) and print only the number that are not correctly formatted, returning non-zero
+                   if there are any. Quicker than --diff for a simple pass or fail.
+
+    --preserve-order
+                   Keep the keys of containers, ports etc. in their existing order, rather than sorting them.
+    --sort-keys-only
+                   Sort the keys, and order the fields, as in the correct format, but keep the existing indent of
+                   each FILE, eg: two spaces or a tab, for smaller diffs when migrating.
+    --compact      Write the rockons on a single line, without any indent, eg: to embed them elsewhere. This is not
+                   the correct format of the registry, so FILE(s) in it show as changed.
+
+    --stdin        Read a single rockon from stdin instead of FILE(s). --write prints the result to stdout.
+    --name         File name of the stdin rockon, used to check it against root.json.
+                   Default: root.json is not checked
+    --jsonc        Allow // and /* */ comments in FILE(s), as in JSONC. They're not part of the correct format,
+                   so --write removes them.
+
+    --files-from   File listing further FILE(s) to check, one per line. Blank lines, and lines starting with #
+                   are ignored.
+    --changed-only Check only the rockons that git reports as changed, staged or not, or new. Any FILE(s) limit
+                   them to those paths. Must be run inside a git repository.
+
+    --ignore       Skip any of FILE(s) matching this pattern, by its base name or full path, eg: 'template*.json'
+                   or 'rockons/test/*'. May be repeated.
+    -R, --recursive
+                   Walk any directories in FILE(s) for *.json (and YAML) rockons, rather than only their top level.
 
     -r, --root     root.json file used to verify that the rockon is mentioned in said file.
-                   Default: same directory as FILE
+                   It may also be a http(s) URL, eg: the registry's own, which is fetched once, with the
+                   --url-timeout, but cannot be written.
+                   Default: $ROCKON_ROOT if set, otherwise the same directory as FILE
+    --output-index File to write root.json to, rather than in-place, eg: to review a regenerated index. There may
+                   only be one root.json, but it may be a remote --root. Implies --write-index-only, unless --write
+                   is passed.
+                   Default: the root.json itself
+    --no-index     Skip root.json altogether, neither reading, checking nor writing it.
+    --index-format Indentation root.json is written with, one of: 2space, 4space, tab.
+                   Default: 4space
+    --check-index-names
+                   Warn when a root.json file name does not match its name, eg: plex -> plex-lsio.json.
+                   --write corrects the entry, but does not rename the file.
+    --check-index-sorted
+                   Check that each root.json is already correctly formatted, sorted and indented as --write would
+                   write it, returning 4 if not, with or without --check. Nothing is written.
+
+    --fail-on-warning
+                   Exit non-zero if anything at all is warned about, as if it were an error, with or without
+                   --check. GitHub annotations are then errors too.
+    --warn-duplicate-ports
+                   Only warn, rather than error, when two ports share a host_default.
+    --strict-images
+                   Error, rather than warn, when a container image is not a plausible docker image reference.
+    --strict-sizes Warn when a volume min_size is implausibly small or large, as it is in KB.
+    --strict-fields
+                   Error on a field that is not part of a rockon, eg: a typo like "webiste", as it would be dropped
+                   by --write. Only the first in each FILE is found.
+    --strict-metadata
+                   Warn when a description or label is empty, of the Rock-on, or any of its ports, volumes,
+                   environment, devices or custom_config. Also when the Rock-on's description is only its name
+                   or version, or is shorter than --min-description-length.
+    --min-description-length
+                   Number of characters a Rock-on's description should have at least, under --strict-metadata.
+                   Default: 20
+    --check-html   Warn when more_info has unclosed or mismatched tags, tags that could run code, eg: <script>,
+                   event handlers, eg: onclick, or javascript: URLs.
+    --custom-config-allow
+                   File listing the custom_config keys that install handlers expect, one per line, warning about any
+                   others. Blank lines, and lines starting with # are ignored.
+    --strict-urls  Error, rather than warn, when the website or icon is not an absolute http(s) URL.
+    --check-urls   Request the website and icon, warning if they do not respond with success. Any proxy set in
+                   the environment, eg: HTTPS_PROXY or NO_PROXY, is used. Errors with --strict-urls.
+    --url-timeout  Timeout for each --check-urls request.
+                   Default: 5s
+
+    --max-errors   Stop checking further FILE(s) once this many errors have been found, 0 for no limit.
+                   Default: 0
+    -j, --jobs     Number of FILE(s) to check at once. The output is the same as checking them one at a time.
+                   Default: 1
+    --cpuprofile   Write a pprof CPU profile of checking the FILE(s) to this file, eg: for go tool pprof.
+    --memprofile   Write a pprof memory profile to this file, once the FILE(s) have been checked.
+
+    --format       Output format, one of: text, json, github, sarif, junit. json prints a report of all the
+                   FILE(s) to stdout, including any diffs, while logging continues on stderr. github prints GitHub
+                   Actions annotations to stdout. sarif prints a SARIF log to stdout, for code scanning. junit
+                   prints a JUnit XML test suite to stdout, with a test case for each FILE.
+                   Default: text
+
+    --list-names   Print the name of each rockon in FILE(s), sorted one per line, and exit. Nothing is checked,
+                   diffed or written.
+    --init         Write a skeleton rockon of this name to NAME.json, lowercased, beside root.json, add it to
+                   root.json, and exit. An existing rockon is only overwritten with --write.
+    --schema       Print the JSON Schema of a rockon and exit.
+    --explain-exit Print the meaning of each exit code and exit.
+    --version      Print the version, the commit it was built from, and the Go version, and exit.
 
     -v, --verbose  Enable more logging
     --debug        Enable debug logging
+    -q, --quiet    Only log errors
+    --timings      Log how long reading and formatting each FILE took, and the total, to find the slow ones.
+                   Implies --verbose.
+    --color        When to color the logs, one of: auto, always, never. auto colors them only when stderr is a
+                   terminal.
+                   Default: auto
 `
 
 var (
 	checkFlag, diffFlag, writeFlag, verboseFlag, debugFlag bool
-	rootFlag, rootFile                                     string
+	writeIndexOnlyFlag, quietFlag, preserveOrderFlag       bool
+	warnDuplicatePortsFlag, strictImagesFlag               bool
+	strictURLsFlag, checkURLsFlag, checkIndexNamesFlag     bool
+	noIndexFlag, checkIndexSortedFlag                      bool
+	strictSizesFlag, listNamesFlag, countOnlyFlag          bool
+	strictMetadataFlag, jsoncFlag, checkHTMLFlag           bool
+	sortKeysOnlyFlag, strictFieldsFlag, diffStatFlag       bool
+	compactFlag                                            bool
+	urlTimeoutFlag                                         time.Duration
+	ignoreFlag                                             patterns
+	jobsFlag, diffContextFlag, maxErrorsFlag               int
+	minDescriptionLengthFlag                               int
+	stdinFlag, recursiveFlag, schemaFlag, explainExitFlag  bool
+	rootFlag, rootFile, nameFlag, formatFlag               string
+	filesFromFlag, outDirFlag, initFlag, indexFormatFlag   string
+	customConfigAllowFlag                                  string
+	cpuProfileFlag, memProfileFlag, colorFlag              string
+	diffOutputFlag, outputIndexFlag                        string
+	customConfigKeys                                       []string // Read from --custom-config-allow
+	changedOnlyFlag, selftestFlag, timingsFlag             bool
+	failOnWarningFlag, checkFormatFlag, versionFlag        bool
 	logger                                                 *slog.Logger
+	display                                                *slog.Logger // Logs to stderr, without recording problems
+	logOptions                                             *tint.Options
 )
 
 func parseFlags() {
 	if len(os.Args) == 1 {
 		flag.Usage()
-		os.Exit(1)
+		os.Exit(exitUsage)
 	}
 
 	flag.BoolVar(&checkFlag, "c", false, "check the file")
 	flag.BoolVar(&checkFlag, "check", false, "check the file")
+	flag.BoolVar(&checkFormatFlag, "check-format", false, "check only the formatting of the file")
 	flag.BoolVar(&diffFlag, "d", false, "diff the file")
 	flag.BoolVar(&diffFlag, "diff", false, "diff the file")
+	flag.IntVar(&diffContextFlag, "diff-context", 3, "lines of context in diffs")
+	flag.BoolVar(&diffStatFlag, "diff-stat", false, "print the lines changed in each file")
+	flag.StringVar(&diffOutputFlag, "diff-output", "", "file to write the diffs to")
 	flag.BoolVar(&writeFlag, "w", false, "write the file")
 	flag.BoolVar(&writeFlag, "write", false, "write the file")
+	flag.StringVar(&outDirFlag, "out-dir", "", "directory to write to")
+	flag.BoolVar(&writeIndexOnlyFlag, "write-index-only", false, "write only the root.json")
+	flag.BoolVar(&selftestFlag, "selftest", false, "check formatting is idempotent")
+	flag.BoolVar(&countOnlyFlag, "count-only", false, "print the number of files not correctly formatted")
+	flag.BoolVar(&preserveOrderFlag, "preserve-order", false, "keep the existing order of keys")
+	flag.BoolVar(&sortKeysOnlyFlag, "sort-keys-only", false, "keep the existing indent")
+	flag.BoolVar(&compactFlag, "compact", false, "write the rockons on a single line")
+	flag.BoolVar(&stdinFlag, "stdin", false, "read the rockon from stdin")
+	flag.StringVar(&nameFlag, "name", "", "file name of the stdin rockon")
+	flag.BoolVar(&jsoncFlag, "jsonc", false, "allow comments in the input")
+	flag.StringVar(&filesFromFlag, "files-from", "", "file listing the files to check")
+	flag.BoolVar(&changedOnlyFlag, "changed-only", false, "check the files changed in git")
+	flag.Var(&ignoreFlag, "ignore", "skip files matching this pattern")
+	flag.BoolVar(&recursiveFlag, "R", false, "walk directories recursively")
+	flag.BoolVar(&recursiveFlag, "recursive", false, "walk directories recursively")
 	flag.StringVar(&rootFlag, "r", "", "root.json file to check")
 	flag.StringVar(&rootFlag, "root", "", "root.json file to check")
+	flag.StringVar(&outputIndexFlag, "output-index", "", "file to write root.json to")
+	flag.BoolVar(&noIndexFlag, "no-index", false, "skip root.json")
+	flag.StringVar(&indexFormatFlag, "index-format", "4space", "indentation of root.json")
+	flag.BoolVar(&checkIndexNamesFlag, "check-index-names", false, "check root.json file names match")
+	flag.BoolVar(&checkIndexSortedFlag, "check-index-sorted", false, "check root.json is correctly formatted")
+	flag.BoolVar(&failOnWarningFlag, "fail-on-warning", false, "exit non-zero on any warning")
+	flag.BoolVar(&warnDuplicatePortsFlag, "warn-duplicate-ports", false, "only warn on duplicate host ports")
+	flag.BoolVar(&strictImagesFlag, "strict-images", false, "error on implausible images")
+	flag.BoolVar(&strictSizesFlag, "strict-sizes", false, "warn on implausible volume sizes")
+	flag.BoolVar(&strictFieldsFlag, "strict-fields", false, "error on unknown fields")
+	flag.BoolVar(&strictMetadataFlag, "strict-metadata", false, "warn on empty descriptions and labels")
+	flag.IntVar(&minDescriptionLengthFlag, "min-description-length", 20, "shortest description without a warning")
+	flag.BoolVar(&checkHTMLFlag, "check-html", false, "check more_info html")
+	flag.StringVar(&customConfigAllowFlag, "custom-config-allow", "", "file listing the allowed custom_config keys")
+	flag.BoolVar(&strictURLsFlag, "strict-urls", false, "error on invalid urls")
+	flag.BoolVar(&checkURLsFlag, "check-urls", false, "request urls")
+	flag.DurationVar(&urlTimeoutFlag, "url-timeout", 5*time.Second, "timeout for url requests")
+	flag.IntVar(&maxErrorsFlag, "max-errors", 0, "stop after this many errors")
+	flag.StringVar(&cpuProfileFlag, "cpuprofile", "", "write a CPU profile to this file")
+	flag.StringVar(&memProfileFlag, "memprofile", "", "write a memory profile to this file")
+	flag.IntVar(&jobsFlag, "j", 1, "number of files to check at once")
+	flag.IntVar(&jobsFlag, "jobs", 1, "number of files to check at once")
+	flag.StringVar(&formatFlag, "format", formatText, "output format")
+	flag.BoolVar(&listNamesFlag, "list-names", false, "print the rockon names")
+	flag.StringVar(&initFlag, "init", "", "write a skeleton rockon of this name")
+	flag.BoolVar(&schemaFlag, "schema", false, "print the JSON Schema")
+	flag.BoolVar(&explainExitFlag, "explain-exit", false, "print the meaning of each exit code")
+	flag.BoolVar(&versionFlag, "version", false, "print the version")
 	flag.BoolVar(&verboseFlag, "v", false, "enable more logging")
 	flag.BoolVar(&verboseFlag, "verbose", false, "enable more logging")
 	flag.BoolVar(&debugFlag, "debug", false, "enable debug logging")
+	flag.BoolVar(&quietFlag, "q", false, "only log errors")
+	flag.BoolVar(&quietFlag, "quiet", false, "only log errors")
+	flag.BoolVar(&timingsFlag, "timings", false, "log how long each file took")
+	flag.StringVar(&colorFlag, "color", colorAuto, "when to color the logs")
 
 	flag.Parse()
 }
 
 func parseFileArgs() (filePaths []string) {
-	for _, f := range flag.Args() {
-		glob, _ := filepath.Glob(f)
-		filePaths = append(filePaths, glob...)
+	args := flag.Args()
+	if filesFromFlag != "" {
+		args = append(args, readList(filesFromFlag, "--files-from")...)
+	}
+
+	for _, arg := range args {
+		for _, f := range expandBraces(arg) {
+			glob, _ := filepath.Glob(f)
+			if len(glob) == 0 {
+				logger.Error("No files matched", slog.String("file", f), rule(ruleNoMatch))
+				os.Exit(exitUsage)
+			}
+			for _, g := range glob {
+				filePaths = append(filePaths, expandDir(g)...)
+			}
+		}
+	}
+	return filePaths
+}
+
+// patterns is a flag that may be repeated, collecting each of its values.
+type patterns []string
+
+func (p *patterns) String() string {
+	return strings.Join(*p, ",")
+}
+
+func (p *patterns) Set(s string) error {
+	if _, err := filepath.Match(s, ""); err != nil {
+		return err
 	}
+	*p = append(*p, s)
+	return nil
+}
 
-	for i, f := range filePaths {
-		files, err := os.ReadDir(f)
+// ignoreFiles returns files without those matching any of the --ignore patterns, either by base name or full path.
+func ignoreFiles(files []string) (kept []string) {
+	for _, f := range files {
+		ignored := false
+		for _, pattern := range ignoreFlag {
+			base, _ := filepath.Match(pattern, filepath.Base(f))
+			full, _ := filepath.Match(pattern, f)
+			if base || full {
+				logger.Info("Ignoring", slog.String("file", f), slog.String("pattern", pattern))
+				ignored = true
+				break
+			}
+		}
+		if !ignored {
+			kept = append(kept, f)
+		}
+	}
+	return kept
+}
+
+// readList reads the list in f, given by the flag name, one entry per line, eg: the FILE(s) of --files-from. Blank
+// lines, and lines starting with # are ignored.
+func readList(f, name string) (entries []string) {
+	data, err := os.ReadFile(f)
+	if err != nil {
+		logger.Error("Reading "+name, slog.String("file", f), slog.Any("err", err))
+		os.Exit(exitUsage)
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		entries = append(entries, line)
+	}
+	return entries
+}
+
+// expandDir returns the files in f if it's a directory, or otherwise just f.
+func expandDir(f string) []string {
+	files, err := os.ReadDir(f)
+	if err != nil {
+		return []string{f} // What we got was not a directory, so we can leave it be
+	}
+	if recursiveFlag {
+		return walkDir(f)
+	}
+
+	entries := []string{}
+	for _, e := range files {
+		if !e.IsDir() {
+			entries = append(entries, filepath.Join(f, e.Name()))
+		}
+	}
+	return entries
+}
+
+// walkDir returns all the *.json rockons below dir, skipping any root.json. Symlinked directories are not followed,
+// to avoid loops.
+func walkDir(dir string) (entries []string) {
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
-			continue // What we got was not a directory, so we can leave it be
+			logger.Warn("Walking directory", slog.String("path", path), slog.Any("err", err), rule(ruleUnreadable))
+			return nil
 		}
+		if d.IsDir() || (filepath.Ext(path) != ".json" && !isYAML(path)) || d.Name() == "root.json" {
+			return nil
+		}
+		if d.Type()&fs.ModeSymlink != 0 {
+			if stat, err := os.Stat(path); err != nil || stat.IsDir() {
+				return nil
+			}
+		}
+		logger.Debug("Found rockon", slog.String("file", path))
+		entries = append(entries, path)
+		return nil
+	})
+	return entries
+}
 
-		entries := []string{}
-		for _, e := range files {
-			if !e.IsDir() {
-				entries = append(entries, filepath.Join(f, e.Name()))
+// readInput reads the rockon from f, or from stdin when --stdin is passed.
+func readInput(f string) ([]byte, error) {
+	if stdinFlag {
+		return io.ReadAll(os.Stdin)
+	}
+	return os.ReadFile(f)
+}
+
+// sortedKeys returns the keys of m in sorted order, so that output is in a stable order.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := maps.Keys(m)
+	slices.Sort(keys)
+	return keys
+}
+
+// listNames prints the name of each rockon in files, sorted, one per line. Files that aren't rockons, eg: root.json,
+// are skipped, and those that can't be read are carried on past, but still fail it.
+func listNames(files []string) (code int) {
+	names := []string{}
+	for _, f := range files {
+		data, err := readInput(f)
+		if err != nil {
+			logger.Error("Reading file", slog.String("file", f), slog.Any("err", err), rule(ruleUnreadable))
+			code = exitBadFile
+			continue
+		}
+		data, ok := checkEncoding(logger, f, data)
+		if !ok {
+			code = exitBadFile
+			continue
+		}
+		if isYAML(f) {
+			if data, err = yaml.YAMLToJSON(data); err != nil {
+				logger.Info("Not a rockon, skipping", slog.String("file", f), slog.Any("err", err))
+				continue
 			}
+		} else if jsoncFlag {
+			data = stripComments(data)
 		}
-		head := filePaths[:i]
-		if i == 0 {
-			head = []string{}
+		var rockon model.RockOn
+		if err := json.Unmarshal(data, &rockon); err != nil {
+			logger.Info("Not a rockon, skipping", slog.String("file", f), slog.Any("err", err))
+			continue
 		}
-		tail := filePaths[i+1:]
-		filePaths = append(head, entries...)
-		filePaths = append(filePaths, tail...)
+		names = append(names, sortedKeys(rockon)...)
 	}
-	return filePaths
+	slices.Sort(names)
+	for _, name := range names {
+		fmt.Println(name)
+	}
+	return code
+}
+
+// checkFiles checks each of files with checkFile, using up to jobs goroutines. The result of each file is sent on the
+// channel at the same index once it's ready. No more files are started once stop is closed.
+func checkFiles(files []string, jobs int, stop <-chan struct{}) []chan *fileResult {
+	done := make([]chan *fileResult, len(files))
+	for i := range done {
+		done[i] = make(chan *fileResult, 1)
+	}
+	next := make(chan int)
+	go func() {
+		defer close(next)
+		for i := range files {
+			select {
+			case next <- i:
+			case <-stop:
+				return
+			}
+		}
+	}()
+	for j := 0; j < jobs; j++ {
+		go func() {
+			for i := range next {
+				done[i] <- checkFile(files[i])
+			}
+		}()
+	}
+	return done
+}
+
+// checkFile reads, checks and formats the rockon f. It only touches the result it returns, logging to that result's
+// own logger, so that files can be checked concurrently. Checking it against root.json and writing it are left to
+// the caller.
+func checkFile(f string) *fileResult {
+	res := newFileResult(f)
+	logger := res.logger
+	logger.Info("Checking", slog.String("file", f))
+	start := time.Now()
+	data, err := readInput(f)
+	res.data = data
+	if err != nil {
+		logger.Error("Reading file", slog.String("file", f), slog.Any("err", err), rule(ruleUnreadable))
+		res.exitCode = exitBadFile // We should be able to read all the files
+		return res
+	}
+	dataString := string(data)
+	data, ok := checkEncoding(logger, f, data)
+	if !ok {
+		res.exitCode = exitBadFile
+		return res
+	}
+
+	if isYAML(f) {
+		// Checked as JSON, against the JSON generated from it, which is what --write writes and root.json refers to
+		res.out = generatedPath(f)
+		data, err = yaml.YAMLToJSON(data)
+		if err != nil {
+			logger.Error("Converting YAML to JSON", slog.String("file", f), slog.Any("err", err), rule(ruleInvalidYAML))
+			res.exitCode = exitBadFile
+			return res
+		}
+		generated, err := os.ReadFile(res.out)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			logger.Error("Reading file", slog.String("file", res.out), slog.Any("err", err), rule(ruleUnreadable))
+			res.exitCode = exitBadFile
+			return res
+		}
+		dataString = string(generated)
+	}
+
+	if jsoncFlag && !isYAML(f) {
+		// The comments are dropped from the correctly formatted rockon, so the file shows as changed until they're gone
+		data = stripComments(data)
+	}
+
+	result, err := checkOptions(logger).ValidateFile(f, data)
+	var parseErr *validator.ParseError
+	if errors.As(err, &parseErr) {
+		err = parseErr.Err
+		err1 := json.Unmarshal(data, &map[string]string{})
+		if err1 == nil {
+			logger.Info("Possible root.json, skipping", slog.String("file", f))
+			res.skipped = true // It may be the root.json, so skip it
+			return res
+		}
+		if stdinFlag || filepath.Ext(f) == ".json" || isYAML(f) {
+			logger.Error("Unmarshaling json data", slog.String("file", f), slog.Any("err", err), rule(ruleInvalidJSON))
+			res.exitCode = exitBadFile // File was named `.json`, but couldn't be marshalled as expected, so we need to exit.
+			return res
+		}
+		logger.Warn("Non-json file passed as input, skipping", slog.String("file", f), rule(ruleNotRockOn))
+		res.skipped = true // Otherwise, it wasn't a json file, so we shouldn't worry about it.
+		return res
+	}
+	if err != nil {
+		logger.Error("Marshaling to JSON", slog.Any("err", err))
+		res.exitCode = exitInternal // This should basically never happen
+		return res
+	}
+
+	for _, finding := range result.Findings {
+		res.addFinding(finding)
+	}
+	if res.dropped = droppedFields(data, []byte(result.Canonical)); len(res.dropped) > 0 {
+		logger.Warn("Fields are not part of a rockon, so --write would drop them", slog.String("file", f), slog.Any("fields", res.dropped), rule(ruleDroppedFields))
+	}
+
+	indent := canonicalIndent
+	if sortKeysOnlyFlag {
+		indent = detectIndent(dataString)
+	}
+	if compactFlag {
+		indent = ""
+	}
+	res.rockon, res.errs, res.result = result.RockOn, result.Errors, reindent(result.Canonical, indent)
+	res.Changed = dataString != res.result // Not result.Changed, as for YAML it's the generated JSON that matters
+	if res.Changed && strings.TrimRight(dataString, "\r\n") == strings.TrimSuffix(res.result, "\n") {
+		logger.Info("Only the trailing newline differs, files should end with exactly one", slog.String("file", f))
+	}
+	if timingsFlag {
+		logger.Info("Timing", slog.String("file", f), slog.Duration("elapsed", time.Since(start)))
+	}
+
+	if selftestFlag {
+		// Checking the correctly formatted rockon again should leave it as it is, or it would change on every --write
+		opts := checkOptions(nil)
+		opts.CheckURLs = false // Already done once
+		again, err := opts.Validate([]byte(res.result))
+		if err != nil || reindent(again.Canonical, indent) != res.result {
+			logger.Error("Formatting is not idempotent, formatting the result again changes it", slog.String("file", f), slog.Any("err", err), slog.String("diff", unifiedDiff(res.out, res.result, reindent(again.Canonical, indent), diffContextFlag)), rule(ruleNotIdempotent))
+			res.selftestFailed = true
+		}
+	}
+
+	if diffStatFlag && res.Changed {
+		res.insertions, res.deletions = diffStat(dataString, res.result)
+	}
+	if diffFlag || diffOutputFlag != "" {
+		res.patch = unifiedDiff(res.out, dataString, res.result, diffContextFlag)
+	}
+	if diffFlag {
+		res.Diff = res.patch
+	}
+	return res
+}
+
+// checkOptions returns the validator.Options set by the flags, logging to logger.
+func checkOptions(logger *slog.Logger) validator.Options {
+	return validator.Options{
+		WarnDuplicatePorts:   warnDuplicatePortsFlag,
+		StrictImages:         strictImagesFlag,
+		StrictURLs:           strictURLsFlag,
+		StrictSizes:          strictSizesFlag,
+		StrictMetadata:       strictMetadataFlag,
+		MinDescriptionLength: minDescriptionLengthFlag,
+		CheckHTML:            checkHTMLFlag,
+		StrictFields:         strictFieldsFlag,
+		CustomConfigKeys:     customConfigKeys,
+		CheckURLs:            checkURLsFlag,
+		URLTimeout:           urlTimeoutFlag,
+		PreserveOrder:        preserveOrderFlag,
+		Logger:               logger,
+	}
+}
+
+// When to color the logs, for --color.
+const (
+	colorAuto   = "auto"
+	colorAlways = "always"
+	colorNever  = "never"
+)
+
+// noColor returns whether the logs should be left uncolored, going by --color and whether stderr is a terminal.
+func noColor() bool {
+	switch colorFlag {
+	case colorAlways:
+		return false
+	case colorNever:
+		return true
+	}
+	stat, err := os.Stderr.Stat()
+	return err != nil || stat.Mode()&os.ModeCharDevice == 0
 }
 
 func setupLogger(logLevel *slog.LevelVar) *slog.Logger {
-	logOpts := &tint.Options{
-		Level: logLevel,
+	logOptions = &tint.Options{
+		Level:   logLevel,
+		NoColor: noColor(),
 		ReplaceAttr: func(groups []string, attr slog.Attr) slog.Attr {
 			if attr.Key == slog.TimeKey && len(groups) == 0 {
 				return slog.Attr{}
@@ -100,13 +617,96 @@ func setupLogger(logLevel *slog.LevelVar) *slog.Logger {
 			return attr
 		},
 	}
-	logHandler := tint.NewHandler(os.Stderr, logOpts)
+	display = slog.New(tint.NewHandler(os.Stderr, logOptions))
+	logHandler := reportHandler{display.Handler(), nil}
 	logger := slog.New(logHandler)
 	slog.SetDefault(logger)
 	return logger
 }
 
-func checkRootMap(rootMap map[string]string, filename string, rockon model.RockOn) {
+// The rules of the checks made here, rather than by the validator package, either for the findings they return, or
+// logged as a "rule" attribute.
+const (
+	ruleIndexNameMismatch   = "index/name-mismatch"
+	ruleIndexDuplicateFile  = "index/duplicate-file"
+	ruleIndexDuplicateName  = "index/duplicate-name"
+	ruleIndexInvalidFile    = "index/invalid-file-name"
+	ruleIndexOrphan         = "index/orphaned-entry"
+	ruleIndexFileName       = "index/file-name-mismatch"
+	ruleIndexFormat         = "index/format"
+	ruleIndexUnreadable     = "index/unreadable"
+	ruleIndexMissing        = "index/missing"
+	ruleIndexInvalid        = "index/invalid-json"
+	ruleDuplicateRockOnName = "rockon/duplicate-name"
+	ruleNoMatch             = "rockon/no-files-matched"
+	ruleUnreadable          = "rockon/unreadable"
+	ruleByteOrderMark       = "rockon/byte-order-mark"
+	ruleInvalidUTF8         = "rockon/invalid-utf-8"
+	ruleInvalidJSON         = "rockon/invalid-json"
+	ruleInvalidYAML         = "rockon/invalid-yaml"
+	ruleNotRockOn           = "rockon/not-a-rockon"
+	ruleDroppedFields       = "rockon/dropped-fields"
+	ruleNotIdempotent       = "rockon/not-idempotent"
+
+	// ruleInternal is the rule of any other problem, that's not found by a check, eg: a file that can't be written
+	ruleInternal = "rockon-validator/internal"
+)
+
+// rule returns the attribute of the rule id, to log a problem found by a check with.
+func rule(id string) slog.Attr {
+	return slog.String("rule", id)
+}
+
+// An index is a root.json, mapping Rock-on names to their files.
+type index struct {
+	path      string // The root.json, as given
+	entries   map[string]string
+	mode      os.FileMode     // The mode to write the root.json with
+	data      string          // The root.json as read, for --diff-output
+	exists    bool            // Whether the root.json was there to be read, or fetched, rather than about to be created
+	invalid   bool            // The root.json could not be parsed, so it's treated as empty
+	processed map[string]bool // The files checked against this root.json
+}
+
+// readIndex reads the root.json at rootFile. If it doesn't exist yet, that's only warned about, so that a new directory
+// can be checked, and it will be written with the same mode as the file f that's being checked against it.
+func readIndex(rootFile, f string) *index {
+	idx := &index{path: rootFile, entries: map[string]string{}, mode: 0o644, processed: map[string]bool{}}
+	if isRemote(rootFile) {
+		logger.Info("Fetching root.json", slog.String("root.json", rootFile))
+		if err := fetchIndex(rootFile, &idx.entries); err != nil {
+			logger.Error("Fetching root.json", slog.String("root.json", rootFile), slog.Any("err", err), rule(ruleIndexUnreadable))
+		} else {
+			idx.exists = true
+		}
+		return idx
+	}
+	if stat, err := os.Stat(rootFile); err == nil {
+		idx.mode = stat.Mode()
+	} else {
+		if errors.Is(err, fs.ErrNotExist) {
+			if writeFlag || writeIndexOnlyFlag {
+				logger.Info("root.json not found, creating it", slog.String("root.json", rootFile))
+			} else {
+				logger.Warn("root.json not found, pass --write, or --write-index-only, to create it", slog.String("root.json", rootFile), rule(ruleIndexMissing))
+			}
+		}
+		if stat, err := os.Stat(f); err == nil {
+			idx.mode = stat.Mode()
+		}
+	}
+	rootData, err := os.ReadFile(rootFile)
+	idx.data, idx.exists = string(rootData), err == nil
+	if err := json.Unmarshal(rootData, &idx.entries); err != nil && idx.exists {
+		logger.Error("Parsing root.json, expected an object of names to file names", slog.String("root.json", rootFile), slog.Any("err", err), rule(ruleIndexInvalid))
+		idx.invalid = true
+	}
+	logger.Debug("root.json flags", slog.String("rootFlag", rootFlag), slog.String("rootFile", rootFile))
+	return idx
+}
+
+// checkRootMap checks the entry of rootMap for filename is the Rock-on in it, adding one if there is none.
+func checkRootMap(rootMap map[string]string, filename string, rockon model.RockOn) (findings []validator.Finding) {
 	var found bool
 	var foundName string
 	for k := range rootMap {
@@ -119,25 +719,186 @@ func checkRootMap(rootMap map[string]string, filename string, rockon model.RockO
 	for name := range rockon {
 		if found {
 			if name != foundName {
-				slog.Warn("RockOn name does not match", slog.String("root.json", foundName), slog.String("rockon", name), slog.String("file", filepath.Base(filename)))
+				findings = append(findings, validator.NewFinding(validator.SeverityWarning, ruleIndexNameMismatch, "RockOn name does not match", slog.String("root.json", foundName), slog.String("rockon", name), slog.String("file", filepath.Base(filename))))
 			}
 		} else {
 			rootMap[name] = filename
 			logger.Debug("root.json map", slog.Any("rootMap", rootMap))
 		}
 	}
+	return findings
+}
+
+// checkIndexDuplicates ensures no file is referred to by more than one entry of the index.
+func checkIndexDuplicates(index map[string]string, rootFile string) (findings []validator.Finding) {
+	names := map[string][]string{}
+	for _, name := range sortedKeys(index) {
+		names[index[name]] = append(names[index[name]], name)
+	}
+	for _, filename := range sortedKeys(names) {
+		if len(names[filename]) > 1 {
+			findings = append(findings, validator.NewFinding(validator.SeverityError, ruleIndexDuplicateFile, "root.json has more than one entry for the same file", slog.String("root.json", rootFile), slog.String("file", filename), slog.Any("names", names[filename])))
+		}
+	}
+
+	lowered := map[string][]string{}
+	for _, name := range sortedKeys(index) {
+		lowered[strings.ToLower(name)] = append(lowered[strings.ToLower(name)], name)
+	}
+	for _, name := range sortedKeys(lowered) {
+		if len(lowered[name]) > 1 {
+			findings = append(findings, validator.NewFinding(validator.SeverityError, ruleIndexDuplicateName, "root.json has more than one entry for the same name, ignoring case", slog.String("root.json", rootFile), slog.Any("names", lowered[name])))
+		}
+	}
+	return findings
+}
+
+// checkIndexFiles ensures each file name in index is that of a JSON rockon.
+func checkIndexFiles(index map[string]string, rootFile string) (findings []validator.Finding) {
+	for _, name := range sortedKeys(index) {
+		if filename := index[name]; filepath.Ext(filename) != ".json" || filepath.Base(filename) != filename {
+			findings = append(findings, validator.NewFinding(validator.SeverityError, ruleIndexInvalidFile, "root.json file name is not a .json file in the same directory", slog.String("root.json", rootFile), slog.String("name", name), slog.String("file", filename)))
+		}
+	}
+	return findings
+}
+
+// checkDuplicateNames errors when a Rock-on of res has the same name, ignoring case, as one in another file, as only
+// one of them could ever be in a root.json, whichever directories they're in. names are the files each name was first
+// seen in. A YAML rockon and the JSON generated from it are the same file, as they're written to the same one.
+func checkDuplicateNames(names map[string]*fileResult, res *fileResult) (findings []validator.Finding) {
+	for _, name := range sortedKeys(res.rockon) {
+		first, found := names[strings.ToLower(name)]
+		switch {
+		case !found:
+			names[strings.ToLower(name)] = res
+		case first.out != res.out:
+			findings = append(findings, validator.NewFinding(validator.SeverityError, ruleDuplicateRockOnName, "Rock-on name is already used by another file", slog.String("rockon", name), slog.String("first", first.File), slog.String("second", res.File)))
+		}
+	}
+	return findings
+}
+
+// checkOrphans warns about any entries in rootMap with no rockon file behind them, either on disk next to rootFile,
+// or among those processed. They are removed from rootMap under --write.
+func checkOrphans(rootMap map[string]string, rootFile string, processed map[string]bool) (findings []validator.Finding) {
+	for _, name := range sortedKeys(rootMap) {
+		filename := rootMap[name]
+		if processed[filename] {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(filepath.Dir(rootFile), filename)); err == nil {
+			continue
+		}
+		findings = append(findings, validator.NewFinding(validator.SeverityWarning, ruleIndexOrphan, "root.json entry has no rockon file", slog.String("root.json", rootFile), slog.String("name", name), slog.String("file", filename)))
+		if writeFlag || writeIndexOnlyFlag {
+			delete(rootMap, name)
+		}
+	}
+	return findings
+}
+
+// checkIndexNames warns about any entries in rootMap whose file name does not match their name, eg: plex ->
+// plex-lsio.json rather than plex.json. Under --write the entry is corrected, but the file itself is not renamed.
+func checkIndexNames(rootMap map[string]string, rootFile string) (findings []validator.Finding) {
+	for _, name := range sortedKeys(rootMap) {
+		filename := rootMap[name]
+		if strings.EqualFold(strings.TrimSuffix(filename, filepath.Ext(filename)), name) {
+			continue
+		}
+		expected := strings.ToLower(name) + ".json"
+		attrs := []slog.Attr{slog.String("root.json", rootFile), slog.String("name", name), slog.String("file", filename), slog.String("expected", expected)}
+		if !writeFlag && !writeIndexOnlyFlag {
+			findings = append(findings, validator.NewFinding(validator.SeverityWarning, ruleIndexFileName, "root.json file name does not match name", attrs...))
+			continue
+		}
+		findings = append(findings, validator.NewFinding(validator.SeverityWarning, ruleIndexFileName, "Renaming root.json entry to match name, the rockon file itself must be renamed by hand", attrs...))
+		rootMap[name] = expected
+	}
+	return findings
+}
+
+// checkIndexSorted errors when the root.json idx, as read, is not correctly formatted, that is sorted and indented as
+// --write would write it.
+func checkIndexSorted(idx *index) []validator.Finding {
+	if !idx.exists || isRemote(idx.path) {
+		return nil
+	}
+	entries := map[string]string{}
+	if err := json.Unmarshal([]byte(idx.data), &entries); err != nil {
+		return nil // Already an error, as the rockons aren't in it
+	}
+	if idx.data != string(formatIndex(entries)) {
+		return []validator.Finding{validator.NewFinding(validator.SeverityError, ruleIndexFormat, "root.json is not correctly formatted, --write would sort and indent it", slog.String("root.json", idx.path), slog.String("indexFormat", indexFormatFlag))}
+	}
+	return nil
+}
+
+// formatIndex returns the root.json of entries, correctly formatted: sorted, and indented by --index-format.
+func formatIndex(entries map[string]string) []byte {
+	rootJson, _ := json.MarshalIndent(entries, "", indexIndents[indexFormatFlag])
+	return append(rootJson, '\n') // As the rockons end with one
+}
+
+// indexIndents are the indents root.json may be written with, by --index-format.
+var indexIndents = map[string]string{
+	"2space": "  ",
+	"4space": "    ",
+	"tab":    "\t",
+}
+
+// written are the files written to --out-dir, to catch two files of the same name overwriting each other.
+var written = map[string]bool{}
+
+// writeFile writes data to f, or under --out-dir to the file of the same name in that directory, leaving f untouched.
+func writeFile(f string, data []byte, mode os.FileMode) error {
+	if outDirFlag == "" {
+		return os.WriteFile(f, data, mode)
+	}
+	if err := os.MkdirAll(outDirFlag, 0o755); err != nil {
+		return err
+	}
+	out := filepath.Join(outDirFlag, filepath.Base(f))
+	if written[out] {
+		return fmt.Errorf("%s was already written, by another file of the same name", out)
+	}
+	written[out] = true
+	if err := os.WriteFile(out, data, mode); err != nil {
+		return err
+	}
+	return os.Chmod(out, mode) // WriteFile only sets the mode of new files
 }
 
 func main() {
 	logLevel := &slog.LevelVar{}
 	logLevel.Set(slog.LevelWarn)
-	logger = setupLogger(logLevel)
-
 	flag.Usage = func() { fmt.Fprint(os.Stderr, usage) }
 
 	parseFlags()
 
-	if verboseFlag {
+	logger = setupLogger(logLevel) // Once --color is known
+
+	switch colorFlag {
+	case colorAuto, colorAlways, colorNever:
+	default:
+		logger.Error("Unknown --color", slog.String("color", colorFlag))
+		os.Exit(exitUsage)
+	}
+
+	if rootFlag == "" && !noIndexFlag {
+		rootFlag = os.Getenv("ROCKON_ROOT")
+	}
+
+	if quietFlag && (verboseFlag || debugFlag || timingsFlag) {
+		logger.Error("--quiet cannot be combined with --verbose, --debug or --timings")
+		os.Exit(exitUsage)
+	}
+
+	if quietFlag {
+		logLevel.Set(slog.LevelError)
+	}
+
+	if verboseFlag || timingsFlag { // The timings are logged at info
 		logLevel.Set(slog.LevelInfo)
 	}
 
@@ -145,85 +906,352 @@ func main() {
 		logLevel.Set(slog.LevelDebug)
 	}
 
-	logger.Debug("Operation flags", slog.Bool("checkFlag", checkFlag), slog.Bool("diffFlag", diffFlag), slog.Bool("writeFlag", writeFlag))
-	logger.Debug("Verbosity flags", slog.Bool("verboseFlag", verboseFlag), slog.Bool("debugFlag", debugFlag))
-	logger.Debug("root.json flags", slog.String("rootFlag", rootFlag), slog.String("rootFile", rootFile))
-	rootMap := map[string]string{}
+	switch formatFlag {
+	case formatText, formatJSON, formatGitHub, formatSARIF, formatJUnit:
+	default:
+		logger.Error("Unknown --format", slog.String("format", formatFlag))
+		os.Exit(exitUsage)
+	}
 
-	var numDiffFiles int
-	for _, f := range parseFileArgs() {
-		logger.Info("Checking", slog.String("file", f))
-		data, err := os.ReadFile(f)
-		if err != nil {
-			logger.Error("Reading file", slog.String("file", f), slog.Any("err", err))
-			os.Exit(1) // We should be able to read all the files
+	if outDirFlag != "" && !writeIndexOnlyFlag {
+		writeFlag = true
+	}
+
+	if outputIndexFlag != "" && !writeFlag {
+		writeIndexOnlyFlag = true
+	}
+
+	if countOnlyFlag && (diffFlag || diffStatFlag || diffOutputFlag != "" || writeFlag || writeIndexOnlyFlag || formatFlag != formatText) {
+		logger.Error("--count-only cannot be combined with --diff, --diff-stat, --diff-output, --write, --write-index-only or --format")
+		os.Exit(exitUsage)
+	}
+
+	if _, found := indexIndents[indexFormatFlag]; !found {
+		logger.Error("Unknown --index-format", slog.String("indexFormat", indexFormatFlag))
+		os.Exit(exitUsage)
+	}
+
+	if isRemote(rootFlag) && ((writeFlag || writeIndexOnlyFlag) && outputIndexFlag == "" || initFlag != "") {
+		logger.Error("A remote --root cannot be written, so cannot be combined with --write, --write-index-only, --out-dir or --init, except to --output-index", slog.String("root", rootFlag))
+		os.Exit(exitUsage)
+	}
+
+	if noIndexFlag && (rootFlag != "" || writeIndexOnlyFlag || outputIndexFlag != "" || checkIndexNamesFlag || checkIndexSortedFlag) {
+		logger.Error("--no-index cannot be combined with --root, --write-index-only, --output-index, --check-index-names or --check-index-sorted")
+		os.Exit(exitUsage)
+	}
+
+	if sortKeysOnlyFlag && preserveOrderFlag {
+		logger.Error("--sort-keys-only cannot be combined with --preserve-order, as it only sorts the keys")
+		os.Exit(exitUsage)
+	}
+
+	if compactFlag && sortKeysOnlyFlag {
+		logger.Error("--compact cannot be combined with --sort-keys-only, as it has no indent to keep")
+		os.Exit(exitUsage)
+	}
+
+	if diffContextFlag < 0 {
+		logger.Error("--diff-context cannot be negative", slog.Int("diffContext", diffContextFlag))
+		os.Exit(exitUsage)
+	}
+
+	if maxErrorsFlag < 0 {
+		logger.Error("--max-errors cannot be negative", slog.Int("maxErrors", maxErrorsFlag))
+		os.Exit(exitUsage)
+	}
+
+	if minDescriptionLengthFlag < 0 {
+		logger.Error("--min-description-length cannot be negative", slog.Int("minDescriptionLength", minDescriptionLengthFlag))
+		os.Exit(exitUsage)
+	}
+
+	if jobsFlag < 1 {
+		logger.Error("--jobs must be at least 1", slog.Int("jobs", jobsFlag))
+		os.Exit(exitUsage)
+	}
+
+	if schemaFlag {
+		schema, _ := json.MarshalIndent(model.Schema(), "", "    ")
+		fmt.Println(string(schema))
+		os.Exit(exitOK)
+	}
+
+	if explainExitFlag {
+		explainExit()
+		os.Exit(exitOK)
+	}
+
+	if versionFlag {
+		printVersion()
+		os.Exit(exitOK)
+	}
+
+	if initFlag != "" {
+		if flag.NArg() > 0 || stdinFlag || filesFromFlag != "" || changedOnlyFlag {
+			logger.Error("--init cannot be combined with FILE(s), --stdin, --files-from or --changed-only")
+			os.Exit(exitUsage)
+		}
+		os.Exit(initRockon(initFlag))
+	}
+
+	logger.Debug("Operation flags", slog.Bool("checkFlag", checkFlag), slog.Bool("checkFormatFlag", checkFormatFlag), slog.Bool("diffFlag", diffFlag), slog.Bool("diffStatFlag", diffStatFlag), slog.String("diffOutputFlag", diffOutputFlag), slog.Bool("writeFlag", writeFlag), slog.Bool("writeIndexOnlyFlag", writeIndexOnlyFlag), slog.Bool("listNamesFlag", listNamesFlag), slog.Bool("countOnlyFlag", countOnlyFlag), slog.String("outDirFlag", outDirFlag), slog.Bool("selftestFlag", selftestFlag))
+	logger.Debug("Output flags", slog.String("formatFlag", formatFlag), slog.Bool("preserveOrderFlag", preserveOrderFlag), slog.Bool("sortKeysOnlyFlag", sortKeysOnlyFlag), slog.Bool("compactFlag", compactFlag), slog.Int("jobsFlag", jobsFlag), slog.Int("diffContextFlag", diffContextFlag), slog.Int("maxErrorsFlag", maxErrorsFlag), slog.String("cpuProfileFlag", cpuProfileFlag), slog.String("memProfileFlag", memProfileFlag))
+	logger.Debug("Input flags", slog.Bool("stdinFlag", stdinFlag), slog.String("nameFlag", nameFlag), slog.Bool("recursiveFlag", recursiveFlag), slog.String("filesFromFlag", filesFromFlag), slog.Bool("changedOnlyFlag", changedOnlyFlag), slog.Bool("jsoncFlag", jsoncFlag), slog.Any("ignoreFlag", ignoreFlag))
+	logger.Debug("Check flags", slog.Bool("failOnWarningFlag", failOnWarningFlag), slog.Bool("warnDuplicatePortsFlag", warnDuplicatePortsFlag), slog.Bool("strictImagesFlag", strictImagesFlag), slog.Bool("strictURLsFlag", strictURLsFlag), slog.Bool("strictSizesFlag", strictSizesFlag), slog.Bool("strictMetadataFlag", strictMetadataFlag), slog.Int("minDescriptionLengthFlag", minDescriptionLengthFlag), slog.Bool("checkHTMLFlag", checkHTMLFlag), slog.Bool("strictFieldsFlag", strictFieldsFlag), slog.String("customConfigAllowFlag", customConfigAllowFlag))
+	logger.Debug("URL flags", slog.Bool("checkURLsFlag", checkURLsFlag), slog.Duration("urlTimeoutFlag", urlTimeoutFlag))
+	logger.Debug("Verbosity flags", slog.Bool("verboseFlag", verboseFlag), slog.Bool("debugFlag", debugFlag), slog.Bool("quietFlag", quietFlag), slog.Bool("timingsFlag", timingsFlag), slog.String("colorFlag", colorFlag))
+	logger.Debug("root.json flags", slog.String("rootFlag", rootFlag), slog.String("rootFile", rootFile), slog.Bool("checkIndexNamesFlag", checkIndexNamesFlag), slog.Bool("checkIndexSortedFlag", checkIndexSortedFlag), slog.Bool("noIndexFlag", noIndexFlag), slog.String("indexFormatFlag", indexFormatFlag), slog.String("outputIndexFlag", outputIndexFlag))
+
+	if customConfigAllowFlag != "" {
+		customConfigKeys = append([]string{}, readList(customConfigAllowFlag, "--custom-config-allow")...) // Empty, but set
+	}
+
+	if changedOnlyFlag && (stdinFlag || filesFromFlag != "") {
+		logger.Error("--changed-only cannot be combined with --stdin or --files-from")
+		os.Exit(exitUsage)
+	}
+
+	var files []string
+	if changedOnlyFlag {
+		files = changedFiles(flag.Args())
+	} else {
+		files = parseFileArgs()
+	}
+	files = ignoreFiles(files)
+	if stdinFlag {
+		if outDirFlag != "" {
+			logger.Error("--out-dir cannot be combined with --stdin, as --write prints the result to stdout")
+			os.Exit(exitUsage)
+		}
+		if flag.NArg() > 0 || filesFromFlag != "" {
+			logger.Error("FILE arguments cannot be combined with --stdin", slog.Any("files", flag.Args()), slog.String("filesFrom", filesFromFlag))
+			os.Exit(exitUsage)
+		}
+		files = []string{"stdin"} // Used as the file name in logs and diffs
+	}
+
+	if listNamesFlag {
+		os.Exit(listNames(files))
+	}
+
+	indexes := map[string]*index{}    // Each root.json used, by absolute path, so files in different directories use their own
+	names := map[string]*fileResult{} // The file each Rock-on was first seen in, by lowercased name
+
+	var numDiffFiles, numInvalidFiles, numIndexErrors, numWriteErrors, numSelftestFailures int
+	var numBadFiles, numInternalErrors int // Files that couldn't be checked, which are carried on past
+	numUnsortedIndexes := 0                // For --check-index-sorted
+
+	// openIndex returns the root.json at rootFile, reading and checking it by itself the first time, for file f
+	openIndex := func(rootFile, f string) *index {
+		// The same directory may be given in different ways, eg: ./a/x.json and /abs/a/y.json
+		rootKey, err := filepath.Abs(rootFile)
+		if err != nil || isRemote(rootFile) {
+			rootKey = rootFile
+		}
+		if idx, read := indexes[rootKey]; read {
+			return idx
+		}
+		idx := readIndex(rootFile, f)
+		if idx.invalid {
+			numIndexErrors++
+		}
+		numIndexErrors += reportFindings(nil, checkIndexDuplicates(idx.entries, rootFile))
+		numIndexErrors += reportFindings(nil, checkIndexFiles(idx.entries, rootFile))
+		if checkIndexSortedFlag {
+			numUnsortedIndexes += reportFindings(nil, checkIndexSorted(idx))
+		}
+		indexes[rootKey] = idx
+		return idx
+	}
+
+	if len(files) == 0 && rootFlag != "" && !noIndexFlag {
+		// Only the root.json to check, eg: in CI after it alone changed
+		logger.Info("No FILE(s), checking only root.json", slog.String("root.json", rootFlag))
+		openIndex(rootFlag, rootFlag)
+	}
+
+	if !startProfiling() {
+		os.Exit(exitWrite)
+	}
+
+	numErrors := 0 // Logged so far, for --max-errors
+	stop := make(chan struct{})
+	start := time.Now()
+	for i, done := range checkFiles(files, jobsFlag, stop) {
+		if len(results) > 0 {
+			numErrors += results[len(results)-1].errorCount()
+		}
+		if maxErrorsFlag > 0 && numErrors >= maxErrorsFlag {
+			current = nil
+			logger.Warn("Stopping early, as --max-errors was reached", slog.Int("maxErrors", maxErrorsFlag), slog.Int("unchecked", len(files)-i))
+			close(stop)
+			break
+		}
+
+		f := files[i]
+		res := <-done // Taken in order, so that the output doesn't depend on which file finished first
+		current = res
+		results = append(results, res)
+		os.Stderr.Write(res.log.Bytes())
+		switch res.exitCode {
+		case exitBadFile:
+			numBadFiles++
+			continue
+		case exitInternal:
+			numInternalErrors++
+			continue
+		}
+
+		current = nil // Any problems with root.json are the run's, not this file's
+		indexName := filepath.Base(res.out)
+		if stdinFlag {
+			indexName = nameFlag // There's no file name to go on, so only check root.json if told what it is
+		}
+		if noIndexFlag {
+			indexName = ""
 		}
-		dataString := string(data)
 
 		rootFile = rootFlag
 		if rootFlag == "" {
 			rootFile = filepath.Join(filepath.Dir(f), "root.json")
 		}
-		rootData, _ := os.ReadFile(rootFile)
-		json.Unmarshal(rootData, &rootMap)
-		logger.Debug("root.json flags", slog.String("rootFlag", rootFlag), slog.String("rootFile", rootFile))
-
-		var rockon model.RockOn
-		err = json.Unmarshal(data, &rockon)
-		if err != nil {
-			err1 := json.Unmarshal(data, &rootMap)
-			if err1 == nil {
-				logger.Warn("Possible root.json, skipping", slog.String("file", f))
-				continue // It may be the root.json, so skip it
-			}
-			if filepath.Ext(f) == ".json" {
-				logger.Error("Unmarshaling json data", slog.String("file", f), slog.Any("err", err))
-				os.Exit(1) // File was named `.json`, but couldn't be marshalled as expected, so we need to exit.
-			}
-			logger.Warn("Non-json file passed as input, skipping", slog.String("file", f))
-			continue // Otherwise, it wasn't a json file, so we shouldn't worry about it.
+		var idx *index
+		if indexName != "" {
+			idx = openIndex(rootFile, f)
 		}
 
-		checkRootMap(rootMap, filepath.Base(f), rockon)
+		if res.skipped {
+			continue
+		}
 
-		result, err := rockon.ToJSON()
-		if err != nil {
-			logger.Error("Marshaling to JSON", slog.Any("err", err))
-			os.Exit(1) // This should basically never happen
+		if indexName != "" && len(res.rockon) == 1 { // Which entry belongs in root.json is anyone's guess otherwise
+			reportFindings(nil, checkRootMap(idx.entries, indexName, res.rockon))
+			idx.processed[indexName] = true
 		}
+		current = res
 
-		if dataString != result {
+		res.errs += reportFindings(res, checkDuplicateNames(names, res))
+
+		if res.errs > 0 {
+			numInvalidFiles++
+		}
+		if res.selftestFailed {
+			numSelftestFailures++
+		}
+		if res.Changed {
 			numDiffFiles++
 		}
+		if diffFlag && (formatFlag == formatText || formatFlag == formatGitHub) { // The others are a single document
+			fmt.Println(res.Diff)
+		}
 
-		if diffFlag {
-			aPath := "a/" + strings.TrimPrefix(f, "/")
-			bPath := "b/" + strings.TrimPrefix(f, "/")
-			edits := myers.ComputeEdits(span.URIFromPath(aPath), dataString, result)
-			fmt.Println(gotextdiff.ToUnified(aPath, bPath, dataString, edits))
+		if writeFlag && !writeIndexOnlyFlag {
+			if len(res.dropped) > 0 {
+				logger.Error("Refusing to write, as fields would be dropped", slog.String("file", res.out), slog.Any("fields", res.dropped), rule(ruleDroppedFields))
+				numWriteErrors++
+			} else if stdinFlag {
+				fmt.Print(res.result)
+			} else {
+				stat, _ := os.Stat(f)
+				logger.Debug("Writing rockon", slog.String("file", res.out))
+				err := writeFile(res.out, []byte(res.result), stat.Mode())
+				if err != nil {
+					logger.Error("Writing rockon", slog.String("file", res.out), slog.Any("err", err))
+					numWriteErrors++
+				}
+			}
 		}
+	}
+	current = nil
+	numWriteErrors += stopProfiling()
+	if timingsFlag {
+		logger.Info("Total timing", slog.Int("files", len(results)), slog.Duration("elapsed", time.Since(start)))
+	}
 
-		if writeFlag {
-			stat, _ := os.Stat(f)
-			logger.Debug("Writing rockon", slog.String("file", f))
-			err = os.WriteFile(f, []byte(result), stat.Mode())
-			if err != nil {
-				logger.Error("Writing rockon", slog.String("file", f), slog.Any("err", err))
+	if outputIndexFlag != "" && len(indexes) > 1 && (writeFlag || writeIndexOnlyFlag) {
+		// They'd overwrite each other
+		logger.Error("--output-index can only be written from a single root.json", slog.String("outputIndex", outputIndexFlag), slog.Any("indexes", sortedKeys(indexes)))
+		numWriteErrors++
+	}
+	var indexDiffs []string // For --diff-output
+	for _, rootKey := range sortedKeys(indexes) {
+		idx := indexes[rootKey]
+		rootFile := idx.path
+		if !isRemote(rootFile) { // Only some of the registry's rockons are likely checked out alongside
+			reportFindings(nil, checkOrphans(idx.entries, rootFile, idx.processed))
+		}
+		if checkIndexNamesFlag {
+			reportFindings(nil, checkIndexNames(idx.entries, rootFile))
+		}
+		rootJson := formatIndex(idx.entries)
+		if diffOutputFlag != "" && !isRemote(rootFile) {
+			if idx.exists {
+				indexDiffs = append(indexDiffs, unifiedDiff(rootFile, idx.data, string(rootJson), diffContextFlag))
+			} else {
+				indexDiffs = append(indexDiffs, createdDiff(rootFile, string(rootJson)))
+			}
+		}
+		if !writeFlag && !writeIndexOnlyFlag {
+			continue
+		}
+		if idx.invalid && outputIndexFlag == "" {
+			logger.Error("Refusing to write root.json, as it could not be parsed, and would lose its entries", slog.String("root.json", rootFile), rule(ruleIndexInvalid))
+			numWriteErrors++
+			continue
+		}
+		if outputIndexFlag != "" {
+			if len(indexes) > 1 {
+				continue // Refused above
 			}
-			rootStat, err := os.Stat(rootFile)
-			if os.IsNotExist(err) {
-				rootStat = stat
+			if isRemote(rootFile) && !idx.exists {
+				continue // It would be missing all of the entries that couldn't be fetched
 			}
-			rootJson, _ := json.MarshalIndent(rootMap, "", "    ")
-			logger.Debug("Writing root", slog.String("file", rootFile))
-			err = os.WriteFile(rootFile, rootJson, rootStat.Mode())
-			if err != nil {
-				logger.Error("Writing root", slog.String("file", rootFile), slog.Any("err", err))
+			logger.Debug("Writing root", slog.String("file", outputIndexFlag), slog.String("root.json", rootFile))
+			if err := os.WriteFile(outputIndexFlag, rootJson, idx.mode); err != nil {
+				logger.Error("Writing root", slog.String("file", outputIndexFlag), slog.Any("err", err))
+				numWriteErrors++
 			}
+			continue
 		}
+		logger.Debug("Writing root", slog.String("file", rootFile))
+		err := writeFile(rootFile, rootJson, idx.mode)
+		if err != nil {
+			logger.Error("Writing root", slog.String("file", rootFile), slog.Any("err", err))
+			numWriteErrors++
+		}
+	}
+
+	if countOnlyFlag {
+		fmt.Println(numDiffFiles)
 	}
+	if diffStatFlag && (formatFlag == formatText || formatFlag == formatGitHub) {
+		printDiffStat(results)
+	}
+	if diffOutputFlag != "" {
+		logger.Debug("Writing diffs", slog.String("file", diffOutputFlag))
+		if err := writeDiffOutput(results, indexDiffs); err != nil {
+			logger.Error("Writing diffs", slog.String("file", diffOutputFlag), slog.Any("err", err))
+			numWriteErrors++
+		}
+	}
+
+	printReport()
 
-	if checkFlag {
-		os.Exit(numDiffFiles)
+	switch {
+	case numBadFiles > 0:
+		os.Exit(exitBadFile)
+	case numWriteErrors > 0:
+		os.Exit(exitWrite)
+	case numInternalErrors+numSelftestFailures > 0:
+		os.Exit(exitInternal)
+	case (countOnlyFlag || checkFormatFlag) && numDiffFiles > 0:
+		os.Exit(exitCheckFailed)
+	case checkFlag && numDiffFiles+numInvalidFiles > 0:
+		os.Exit(exitCheckFailed)
+	case checkFlag && numIndexErrors > 0, numUnsortedIndexes > 0:
+		os.Exit(exitIndex)
+	case failOnWarningFlag && warningCount() > 0:
+		os.Exit(exitCheckFailed)
 	}
 }
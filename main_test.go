@@ -0,0 +1,161 @@
+// SPDX-License-Identifier: GPL-3.0-or-later
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/exp/slog" // nee "log/slog"
+)
+
+func TestMain(m *testing.M) {
+	display = slog.New(slog.NewTextHandler(io.Discard, nil))
+	logger = slog.New(reportHandler{display.Handler(), nil})
+	os.Exit(m.Run())
+}
+
+// writeFiles writes each of files, by name, to a new temporary directory, returning its path.
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+const plexJSON = `{
+    "Plex": {
+        "description": "Plex media server, to stream your media",
+        "version": "1.0",
+        "website": "https://plex.tv",
+        "containers": {
+            "plex": {
+                "image": "linuxserver/plex",
+                "launch_order": 1
+            }
+        }
+    }
+}
+`
+
+const plexYAML = `Plex:
+  description: Plex media server, to stream your media
+  version: "1.0"
+  website: https://plex.tv
+  containers:
+    plex:
+      image: linuxserver/plex
+      launch_order: 1
+`
+
+func TestCheckDuplicateNames(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"plex.yaml":      plexYAML,
+		"plex.json":      plexJSON, // As generated from plex.yaml by --write
+		"plex-lsio.json": plexJSON,
+	})
+	tests := []struct {
+		name  string
+		files []string
+		errs  int
+	}{
+		{"YAML next to its generated JSON", []string{"plex.json", "plex.yaml"}, 0},
+		{"generated JSON after its YAML", []string{"plex.yaml", "plex.json"}, 0},
+		{"another file", []string{"plex.json", "plex-lsio.json"}, 1},
+		{"another file than the YAML's", []string{"plex.yaml", "plex-lsio.json"}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			names := map[string]*fileResult{}
+			errs := 0
+			for _, f := range tt.files {
+				res := checkFile(filepath.Join(dir, f))
+				if res.exitCode != exitOK {
+					t.Fatalf("checkFile(%s) exit code = %d, log:\n%s", f, res.exitCode, res.log.String())
+				}
+				errs += len(checkDuplicateNames(names, res))
+			}
+			if errs != tt.errs {
+				t.Errorf("checkDuplicateNames() errs = %d, want %d", errs, tt.errs)
+			}
+		})
+	}
+}
+
+func TestCheckFileNoRockOn(t *testing.T) {
+	f := filepath.Join(writeFiles(t, map[string]string{"empty.json": "{}\n"}), "empty.json")
+	res := checkFile(f)
+	if res.exitCode != exitOK {
+		t.Fatalf("checkFile() exit code = %d, want %d", res.exitCode, exitOK)
+	}
+	if errs := res.errorCount(); errs != 1 {
+		t.Errorf("checkFile() errors = %d, want 1: %+v", errs, res.Problems)
+	}
+	if log := res.log.String(); !strings.Contains(log, "No Rock-on in the file") || !strings.Contains(log, f) {
+		t.Errorf("checkFile() log = %q, want the error with the file", log)
+	}
+}
+
+func TestLineEndings(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		changed bool
+	}{
+		{"LF", plexJSON, false},
+		{"no trailing newline", strings.TrimSuffix(plexJSON, "\n"), true},
+		{"two trailing newlines", plexJSON + "\n", true},
+		{"CRLF", strings.ReplaceAll(plexJSON, "\n", "\r\n"), true},
+		{"CRLF without a trailing newline", strings.TrimSuffix(strings.ReplaceAll(plexJSON, "\n", "\r\n"), "\r\n"), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := checkFile(filepath.Join(writeFiles(t, map[string]string{"plex.json": tt.data}), "plex.json"))
+			if res.exitCode != exitOK {
+				t.Fatalf("checkFile() exit code = %d, log:\n%s", res.exitCode, res.log.String())
+			}
+			if res.Changed != tt.changed {
+				t.Errorf("checkFile() changed = %v, want %v", res.Changed, tt.changed)
+			}
+			if res.result != plexJSON { // Written with LF line endings, and exactly one trailing newline
+				t.Errorf("checkFile() result = %q, want %q", res.result, plexJSON)
+			}
+		})
+	}
+
+	indexFormatFlag = "4space"
+	if got, want := string(formatIndex(map[string]string{"Plex": "plex.json"})), "{\n    \"Plex\": \"plex.json\"\n}\n"; got != want {
+		t.Errorf("formatIndex() = %q, want %q", got, want)
+	}
+}
+
+func TestCheckFileBOM(t *testing.T) {
+	f := filepath.Join(writeFiles(t, map[string]string{"plex.json": "\xef\xbb\xbf" + plexJSON}), "plex.json")
+	res := checkFile(f)
+	if res.exitCode != exitOK || res.errorCount() > 0 {
+		t.Fatalf("checkFile() exit code = %d, log:\n%s", res.exitCode, res.log.String())
+	}
+	if _, found := res.rockon["Plex"]; !found {
+		t.Errorf("checkFile() rockons = %v, want Plex", sortedKeys(res.rockon))
+	}
+	if !res.Changed {
+		t.Error("checkFile() changed = false, want true, as the BOM is removed")
+	}
+	if res.result != plexJSON {
+		t.Errorf("checkFile() result = %q, want %q, without the BOM", res.result, plexJSON)
+	}
+}
+
+func TestCheckFileLatin1(t *testing.T) {
+	latin1 := strings.Replace(plexJSON, "Plex media server", "Plex m\xe9dia server", 1)
+	res := checkFile(filepath.Join(writeFiles(t, map[string]string{"plex.json": latin1}), "plex.json"))
+	if res.exitCode != exitBadFile {
+		t.Errorf("checkFile() exit code = %d, want %d", res.exitCode, exitBadFile)
+	}
+}
@@ -0,0 +1,213 @@
+// SPDX-License-Identifier: GPL-3.0-or-later
+package model
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+
+	"golang.org/x/exp/slices"
+)
+
+// ToJSONPreservingOrder is as ToJSON, except that the keys of any maps, eg: containers or ports, are kept in the
+// order they appear in original, rather than being sorted. The fields of each object keep their usual order.
+func (r RockOn) ToJSONPreservingOrder(original []byte) (string, error) {
+	canonical, err := r.ToJSON()
+	if err != nil {
+		return "", err
+	}
+
+	out, err := parseOrdered(json.NewDecoder(strings.NewReader(canonical)))
+	if err != nil {
+		return "", err
+	}
+	orig, err := parseOrdered(json.NewDecoder(bytes.NewReader(original)))
+	if err != nil {
+		return "", err
+	}
+	reorder(out, orig, reflect.TypeOf(r))
+
+	var b strings.Builder
+	if err := out.write(&b, ""); err != nil {
+		return "", err
+	}
+	b.WriteString("\n") // As json.Encoder does
+	return b.String(), nil
+}
+
+// An orderedNode is a decoded JSON value, that remembers the order of its object keys.
+type orderedNode struct {
+	object, array bool
+	keys          []string       // Keys of an object, in order
+	children      []*orderedNode // Values of an object, in the same order as keys, or elements of an array
+	value         any            // Value of anything else
+}
+
+func parseOrdered(dec *json.Decoder) (*orderedNode, error) {
+	dec.UseNumber()
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	return parseOrderedToken(dec, tok)
+}
+
+func parseOrderedToken(dec *json.Decoder, tok json.Token) (*orderedNode, error) {
+	delim, ok := tok.(json.Delim)
+	if !ok {
+		return &orderedNode{value: tok}, nil
+	}
+
+	n := &orderedNode{object: delim == '{', array: delim == '['}
+	for dec.More() {
+		if n.object {
+			key, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			n.keys = append(n.keys, key.(string))
+		}
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		child, err := parseOrderedToken(dec, tok)
+		if err != nil {
+			return nil, err
+		}
+		n.children = append(n.children, child)
+	}
+	_, err := dec.Token() // The closing delimiter
+	return n, err
+}
+
+// child returns the value of key in an object, matching case-insensitively if need be, as json.Unmarshal does for
+// struct fields.
+func (n *orderedNode) child(key string) *orderedNode {
+	if n == nil || !n.object {
+		return nil
+	}
+	for i, k := range n.keys {
+		if k == key {
+			return n.children[i]
+		}
+	}
+	for i, k := range n.keys {
+		if strings.EqualFold(k, key) {
+			return n.children[i]
+		}
+	}
+	return nil
+}
+
+// reorder sorts the keys of any maps in out, of type t, into the order they have in orig. Keys not in orig are
+// left at the end, in their existing order.
+func reorder(out, orig *orderedNode, t reflect.Type) {
+	if out == nil || orig == nil {
+		return
+	}
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Map:
+		if !out.object || !orig.object {
+			return
+		}
+		position := func(key string) int {
+			if i := slices.Index(orig.keys, key); i >= 0 {
+				return i
+			}
+			return len(orig.keys)
+		}
+		order := make([]int, len(out.keys))
+		for i := range order {
+			order[i] = i
+		}
+		slices.SortStableFunc(order, func(a, b int) bool { return position(out.keys[a]) < position(out.keys[b]) })
+
+		keys, children := make([]string, len(order)), make([]*orderedNode, len(order))
+		for i, j := range order {
+			keys[i], children[i] = out.keys[j], out.children[j]
+		}
+		out.keys, out.children = keys, children
+		for i, key := range out.keys {
+			var origChild *orderedNode
+			if j := slices.Index(orig.keys, key); j >= 0 {
+				origChild = orig.children[j]
+			}
+			reorder(out.children[i], origChild, t.Elem())
+		}
+	case reflect.Struct:
+		for i, key := range out.keys {
+			if field, found := fieldByJSONName(t, key); found {
+				reorder(out.children[i], orig.child(key), field.Type)
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := range out.children {
+			if i < len(orig.children) {
+				reorder(out.children[i], orig.children[i], t.Elem())
+			}
+		}
+	}
+}
+
+func fieldByJSONName(t reflect.Type, name string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if tag == name || (tag == "" && field.Name == name) {
+			return field, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+// write encodes n as json.Encoder would with an indent of four spaces, and without escaping HTML.
+func (n *orderedNode) write(b *strings.Builder, indent string) error {
+	switch {
+	case n.object || n.array:
+		open, close := "[", "]"
+		if n.object {
+			open, close = "{", "}"
+		}
+		b.WriteString(open)
+		if len(n.children) == 0 {
+			b.WriteString(close)
+			return nil
+		}
+		for i, child := range n.children {
+			if i > 0 {
+				b.WriteString(",")
+			}
+			b.WriteString("\n" + indent + "    ")
+			if n.object {
+				if err := writeValue(b, n.keys[i]); err != nil {
+					return err
+				}
+				b.WriteString(": ")
+			}
+			if err := child.write(b, indent+"    "); err != nil {
+				return err
+			}
+		}
+		b.WriteString("\n" + indent + close)
+		return nil
+	default:
+		return writeValue(b, n.value)
+	}
+}
+
+func writeValue(b *strings.Builder, v any) error {
+	var tmp bytes.Buffer
+	enc := json.NewEncoder(&tmp)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return err
+	}
+	b.Write(bytes.TrimSuffix(tmp.Bytes(), []byte("\n")))
+	return nil
+}
@@ -0,0 +1,157 @@
+// SPDX-License-Identifier: GPL-3.0-or-later
+package model
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/exp/slices"
+)
+
+// unorderedRockOn has maps whose keys are out of alphabetical order, and fields out of their usual order.
+const unorderedRockOn = `{
+    "Web App": {
+        "version": "1.0",
+        "description": "A web app, and its database",
+        "website": "https://example.com",
+        "containers": {
+            "web": {
+                "image": "example/web",
+                "launch_order": 2,
+                "ports": {
+                    "8080": {"host_default": 8080},
+                    "443": {"host_default": 8443},
+                    "80": {"host_default": 8000}
+                },
+                "environment": {
+                    "Z_LAST": {"label": "Z"},
+                    "A_FIRST": {"label": "A"}
+                }
+            },
+            "db": {
+                "image": "example/db",
+                "launch_order": 1
+            }
+        }
+    }
+}
+`
+
+// keyOrder returns keys, quoted, in the order they are first found in out.
+func keyOrder(out string, keys ...string) []string {
+	sorted := slices.Clone(keys)
+	slices.SortStableFunc(sorted, func(a, b string) bool { return strings.Index(out, `"`+a+`"`) < strings.Index(out, `"`+b+`"`) })
+	return sorted
+}
+
+func TestKeyOrder(t *testing.T) {
+	tests := []struct {
+		name     string
+		preserve bool
+		keys     []string
+		want     []string
+	}{
+		{"containers sorted", false, []string{"web", "db"}, []string{"db", "web"}},
+		{"containers preserved", true, []string{"web", "db"}, []string{"web", "db"}},
+		{"ports sorted as strings", false, []string{"8080", "443", "80"}, []string{"443", "80", "8080"}},
+		{"ports preserved", true, []string{"8080", "443", "80"}, []string{"8080", "443", "80"}},
+		{"environment sorted", false, []string{"Z_LAST", "A_FIRST"}, []string{"A_FIRST", "Z_LAST"}},
+		{"environment preserved", true, []string{"Z_LAST", "A_FIRST"}, []string{"Z_LAST", "A_FIRST"}},
+		{"fields in struct order", false, []string{"version", "description", "website"}, []string{"description", "version", "website"}},
+		{"fields in struct order, even preserving", true, []string{"version", "description", "website"}, []string{"description", "version", "website"}},
+	}
+	var r RockOn
+	if err := json.Unmarshal([]byte(unorderedRockOn), &r); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := r.ToJSON()
+			if tt.preserve {
+				out, err = r.ToJSONPreservingOrder([]byte(unorderedRockOn))
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := keyOrder(out, tt.keys...); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("keys in order %q, want %q, in:\n%s", got, tt.want, out)
+			}
+		})
+	}
+}
+
+// linkedRockOn has maps at each level, out of alphabetical order.
+const linkedRockOn = `{
+    "Linked": {
+        "description": "Containers linked together, with custom config",
+        "version": "2",
+        "website": "https://example.com",
+        "containers": {
+            "worker": {
+                "image": "example/worker",
+                "launch_order": 3,
+                "volumes": {"/data": {"label": "Data"}, "/config": {"label": "Config"}},
+                "devices": {"/dev/video0": {"label": "Video"}, "/dev/dri": {"label": "GPU"}}
+            },
+            "app": {"image": "example/app", "launch_order": 2},
+            "cache": {"image": "example/cache", "launch_order": 1}
+        },
+        "container_links": {
+            "worker": [{"name": "queue", "source_container": "cache"}],
+            "app": [{"name": "store", "source_container": "cache"}]
+        },
+        "custom_config": {
+            "url": {"label": "URL"},
+            "key": {"label": "Key"}
+        }
+    }
+}
+`
+
+func TestToJSONPreservingOrderRoundTrip(t *testing.T) {
+	tests := []struct {
+		name     string
+		original string
+		keys     []string // In the order they should be written
+	}{
+		{"nested maps", unorderedRockOn, []string{"web", "8080", "443", "80", "Z_LAST", "A_FIRST", "db"}},
+		{"links and custom config", linkedRockOn, []string{"worker", "/data", "/config", "/dev/video0", "/dev/dri", "app", "cache", "url", "key"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var r RockOn
+			if err := json.Unmarshal([]byte(tt.original), &r); err != nil {
+				t.Fatal(err)
+			}
+			out, err := r.ToJSONPreservingOrder([]byte(tt.original))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := keyOrder(out, tt.keys...); !reflect.DeepEqual(got, tt.keys) {
+				t.Errorf("keys in order %q, want %q, in:\n%s", got, tt.keys, out)
+			}
+
+			var parsed RockOn
+			if err := json.Unmarshal([]byte(out), &parsed); err != nil {
+				t.Fatalf("ToJSONPreservingOrder() output doesn't parse: %v\n%s", err, out)
+			}
+			if !reflect.DeepEqual(parsed, r) {
+				t.Errorf("ToJSONPreservingOrder() output parses to %+v, want %+v", parsed, r)
+			}
+
+			again, err := parsed.ToJSONPreservingOrder([]byte(out))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if again != out {
+				t.Errorf("ToJSONPreservingOrder() of its own output differs:\n%s\nthen:\n%s", out, again)
+			}
+			sorted, _ := r.ToJSON()
+			if len(out) != len(sorted) {
+				t.Errorf("ToJSONPreservingOrder() is %d bytes, want %d, as ToJSON but reordered", len(out), len(sorted))
+			}
+		})
+	}
+}
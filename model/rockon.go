@@ -2,6 +2,7 @@
 package model
 
 import (
+	"bytes"
 	"encoding/json"
 	"strconv"
 	"strings"
@@ -21,10 +22,9 @@ func (r RockOn) ToJSON() (string, error) {
 		return "", err
 	}
 
-	out := strings.ReplaceAll(tmp.String(), "\\u0026", "&")
-	out = strings.ReplaceAll(out, "\\u003c", "<")
-	out = strings.ReplaceAll(out, "\\u003e", ">")
-	return out, nil
+	// &, < and > are left as they are, with HTML escaping off here and in RockonDetails.MarshalJSON. Replacing their
+	// escapes afterwards instead would corrupt an escaped backslash followed by u0026 etc, eg: "\\u0026" -> "\&".
+	return tmp.String(), nil
 }
 
 type RockonDetails struct {
@@ -50,7 +50,14 @@ func (r RockonDetails) MarshalJSON() ([]byte, error) {
 	if r.UI != nil && *r.UI == (UISlug{}) {
 		r.UI = nil
 	}
-	return json.Marshal(ro(r))
+	// Not json.Marshal, which would escape &, < and >, as the encoder in ToJSON doesn't pass SetEscapeHTML on to us
+	var tmp bytes.Buffer
+	enc := json.NewEncoder(&tmp)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(ro(r)); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(tmp.Bytes(), []byte("\n")), nil
 }
 
 type Container struct {
@@ -100,6 +107,22 @@ const (
 	UDP Protocol = "udp"
 )
 
+// UnmarshalJSON lowercases the protocol, so that a mistakenly entered "TCP" is normalised to "tcp".
+func (p *Protocol) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+
+	*p = Protocol(strings.ToLower(s))
+	return nil
+}
+
+// Valid reports whether the protocol is empty (both tcp and udp), tcp, or udp.
+func (p Protocol) Valid() bool {
+	return p == "" || p == TCP || p == UDP
+}
+
 type Volume struct {
 	Description string    `json:"description"`        // A detailed description. Eg: This is where all incoming syncthing data will be stored
 	Label       string    `json:"label"`              // A short label. eg: Data Storage
@@ -115,7 +138,7 @@ type Option [2]string
 //
 // `docker run <...> image/name argument1 argument2="text2"` would be represented as:
 //
-// ["argument1", "argument2="text2"]
+// ["argument1", "argument2=\"text2\""]
 type CmdArgument [2]string
 
 type EnvironmentVar struct {
@@ -0,0 +1,96 @@
+// SPDX-License-Identifier: GPL-3.0-or-later
+package model
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestToJSONHTMLCharacters(t *testing.T) {
+	tests := []struct {
+		name        string
+		description string
+		moreInfo    string
+	}{
+		{"ampersand", "Tom & Jerry", "Tom &amp; Jerry"},
+		{"angle brackets", "a < b > c", "<p>Paragraph</p><br/>"},
+		{"URL with query", "See https://example.com/?a=1&b=2", `<a href="https://example.com/?a=1&b=2">link</a>`},
+		{"escaped backslash", `C:\u0026 is not an escape`, `\<p>`},
+		{"quotes and unicode", `"Quoted" and 'single', café, 日本, 🎬`, "<b>\u00e9</b>"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := RockOn{"Test": {Description: tt.description, MoreInfo: tt.moreInfo, Containers: map[string]Container{}}}
+			out, err := r.ToJSON()
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, escape := range []string{`\u0026`, `\u003c`, `\u003e`} {
+				if strings.Contains(strings.ReplaceAll(out, `\\`, ""), escape) {
+					t.Errorf("ToJSON() escapes as %s:\n%s", escape, out)
+				}
+			}
+
+			var parsed RockOn
+			if err := json.Unmarshal([]byte(out), &parsed); err != nil {
+				t.Fatal(err)
+			}
+			if got := parsed["Test"]; got.Description != tt.description || got.MoreInfo != tt.moreInfo {
+				t.Errorf("ToJSON() round-trips to description %q, more_info %q, want %q, %q", got.Description, got.MoreInfo, tt.description, tt.moreInfo)
+			}
+			again, err := parsed.ToJSON()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if again != out {
+				t.Errorf("ToJSON() is not stable:\n%s\nthen:\n%s", out, again)
+			}
+		})
+	}
+}
+
+func TestToJSONWithoutCustomConfig(t *testing.T) {
+	const original = `{"Test": {"description": "A test", "containers": {}}}`
+	tests := []struct {
+		name         string
+		customConfig map[string]CustomConfig
+	}{
+		{"nil", nil},
+		{"empty", map[string]CustomConfig{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := RockOn{"Test": {Description: "A test", Containers: map[string]Container{}, CustomConfig: tt.customConfig}}
+			out, err := r.ToJSON()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Contains(out, "custom_config") {
+				t.Errorf("ToJSON() has custom_config:\n%s", out)
+			}
+			ordered, err := r.ToJSONPreservingOrder([]byte(original))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ordered != out {
+				t.Errorf("ToJSONPreservingOrder() =\n%s\nwant, as ToJSON():\n%s", ordered, out)
+			}
+
+			var parsed RockOn
+			if err := json.Unmarshal([]byte(original), &parsed); err != nil {
+				t.Fatal(err)
+			}
+			if parsed["Test"].CustomConfig != nil {
+				t.Errorf("Unmarshal() custom_config = %v, want nil", parsed["Test"].CustomConfig)
+			}
+			again, err := parsed.ToJSON()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if again != out {
+				t.Errorf("ToJSON() of the parsed rockon =\n%s\nwant:\n%s", again, out)
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+// SPDX-License-Identifier: GPL-3.0-or-later
+package model
+
+import (
+	"reflect"
+	"strings"
+)
+
+const schemaDraft = "https://json-schema.org/draft/2020-12/schema"
+
+// Schema returns a JSON Schema describing a RockOn, generated from the json tags of the model so that it never falls
+// out of step with it. Fields without omitempty are required, and unknown fields are disallowed.
+func Schema() map[string]any {
+	defs := map[string]any{}
+	schema := schemaFor(reflect.TypeOf(RockOn{}), defs)
+	schema["$schema"] = schemaDraft
+	schema["$defs"] = defs
+	schema["minProperties"] = 1 // A map with a single entry, the Rock-on name.
+	schema["maxProperties"] = 1
+	return schema
+}
+
+func schemaFor(t reflect.Type, defs map[string]any) map[string]any {
+	// The custom types accept more than their kind would suggest, so they need describing by hand.
+	switch t {
+	case reflect.TypeOf(UintValue(0)):
+		return map[string]any{"anyOf": []any{
+			map[string]any{"type": "integer", "minimum": 0},
+			map[string]any{"type": "string", "pattern": "^[0-9]+$"},
+		}}
+	case reflect.TypeOf(StrValue("")):
+		return map[string]any{"type": []string{"string", "integer"}}
+	case reflect.TypeOf(Protocol("")):
+		return map[string]any{"type": "string", "pattern": "^([tT][cC][pP]|[uU][dD][pP])?$"}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return schemaFor(t.Elem(), defs)
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return map[string]any{"type": "integer"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer", "minimum": 0}
+	case reflect.Array:
+		return map[string]any{"type": "array", "items": schemaFor(t.Elem(), defs), "minItems": t.Len(), "maxItems": t.Len()}
+	case reflect.Slice:
+		return map[string]any{"type": "array", "items": schemaFor(t.Elem(), defs)}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": schemaFor(t.Elem(), defs)}
+	case reflect.Struct:
+		if _, found := defs[t.Name()]; !found {
+			defs[t.Name()] = nil // Reserve the name first, in case the struct refers back to itself
+			defs[t.Name()] = structSchema(t, defs)
+		}
+		return map[string]any{"$ref": "#/$defs/" + t.Name()}
+	}
+	return map[string]any{}
+}
+
+func structSchema(t reflect.Type, defs map[string]any) map[string]any {
+	properties := map[string]any{}
+	required := []string{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, opts, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if !field.IsExported() || name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		properties[name] = schemaFor(field.Type, defs)
+		if !strings.Contains(opts, "omitempty") {
+			required = append(required, name)
+		}
+	}
+	return map[string]any{
+		"type":                 "object",
+		"properties":           properties,
+		"required":             required,
+		"additionalProperties": false,
+	}
+}
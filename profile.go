@@ -0,0 +1,60 @@
+// SPDX-License-Identifier: GPL-3.0-or-later
+package main
+
+import (
+	"os"
+	"runtime"
+	"runtime/pprof"
+
+	"golang.org/x/exp/slog" // nee "log/slog"
+)
+
+var cpuProfile *os.File // The --cpuprofile being written, if any
+
+// startProfiling starts the --cpuprofile, if any, returning false if it could not be. stopProfiling must be called
+// once the FILE(s) have been checked.
+func startProfiling() bool {
+	if cpuProfileFlag == "" {
+		return true
+	}
+	f, err := os.Create(cpuProfileFlag)
+	if err != nil {
+		logger.Error("Writing CPU profile", slog.String("file", cpuProfileFlag), slog.Any("err", err))
+		return false
+	}
+	if err := pprof.StartCPUProfile(f); err != nil {
+		f.Close()
+		logger.Error("Writing CPU profile", slog.String("file", cpuProfileFlag), slog.Any("err", err))
+		return false
+	}
+	cpuProfile = f
+	return true
+}
+
+// stopProfiling finishes the --cpuprofile, and writes the --memprofile, if any, returning the number of profiles
+// that could not be written.
+func stopProfiling() (errs int) {
+	if cpuProfile != nil {
+		pprof.StopCPUProfile()
+		if err := cpuProfile.Close(); err != nil {
+			logger.Error("Writing CPU profile", slog.String("file", cpuProfileFlag), slog.Any("err", err))
+			errs++
+		}
+		cpuProfile = nil
+	}
+	if memProfileFlag == "" {
+		return errs
+	}
+	f, err := os.Create(memProfileFlag)
+	if err != nil {
+		logger.Error("Writing memory profile", slog.String("file", memProfileFlag), slog.Any("err", err))
+		return errs + 1
+	}
+	defer f.Close()
+	runtime.GC() // So the profile shows what is still in use, as of the end of the checks
+	if err := pprof.WriteHeapProfile(f); err != nil {
+		logger.Error("Writing memory profile", slog.String("file", memProfileFlag), slog.Any("err", err))
+		errs++
+	}
+	return errs
+}
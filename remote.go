@@ -0,0 +1,34 @@
+// SPDX-License-Identifier: GPL-3.0-or-later
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// isRemote reports whether the root.json rootFile is a URL, eg: the registry's own, rather than a local file.
+func isRemote(rootFile string) bool {
+	return strings.HasPrefix(rootFile, "http://") || strings.HasPrefix(rootFile, "https://")
+}
+
+// fetchIndex requests the remote root.json at url into entries. It's only requested once per run, however many files
+// are checked against it, as each root.json is read once. Any proxy set in the environment is used.
+func fetchIndex(url string, entries *map[string]string) error {
+	client := &http.Client{Timeout: urlTimeoutFlag}
+	resp, err := client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, entries)
+}
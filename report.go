@@ -0,0 +1,354 @@
+// SPDX-License-Identifier: GPL-3.0-or-later
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"golang.org/x/exp/slices"
+	"golang.org/x/exp/slog" // nee "log/slog"
+
+	"github.com/lmittmann/tint"
+
+	"github.com/rockstor/rockon-validator/model"
+	"github.com/rockstor/rockon-validator/validator"
+)
+
+const (
+	formatText   = "text"
+	formatJSON   = "json"
+	formatGitHub = "github"
+	formatSARIF  = "sarif"
+	formatJUnit  = "junit"
+)
+
+// A problem is a warning or error logged while checking a file, or the run as a whole.
+type problem struct {
+	Level   string         `json:"level"`
+	Rule    string         `json:"rule"`
+	Path    string         `json:"path,omitempty"` // Where in the rockon the problem is, if the checks found it
+	Message string         `json:"message"`
+	Attrs   map[string]any `json:"attrs,omitempty"`
+}
+
+type fileResult struct {
+	File     string    `json:"file"`
+	Valid    bool      `json:"valid"`   // No errors, and the file is already correctly formatted
+	Changed  bool      `json:"changed"` // The file differs from its correctly formatted form
+	Diff     string    `json:"diff,omitempty"`
+	Problems []problem `json:"problems"`
+
+	data []byte // The raw file, used to find the line a problem is on
+	out  string // The file the correctly formatted rockon belongs in. Usually File, but see generatedPath.
+
+	// Set while checking the file, possibly alongside others, for the main loop to pick up
+	logger         *slog.Logger
+	display        *slog.Logger // Logs to log without recording problems, for findings which are recorded as they are
+	log            bytes.Buffer // The log output, held back until the file's turn to be reported
+	rockon         model.RockOn
+	result         string // The correctly formatted file
+	errs           int
+	skipped        bool     // Not a rockon, eg: the root.json
+	exitCode       int      // Non-zero if the file couldn't be checked
+	selftestFailed bool     // Formatting the result again changed it
+	dropped        []string // Fields that are not part of a rockon, which writing the result would lose
+	insertions     int      // Lines the correct format adds, for --diff-stat
+	deletions      int      // Lines the correct format removes, for --diff-stat
+	patch          string   // The diff, for --diff-output, whether or not it's reported
+}
+
+type summary struct {
+	Processed     int `json:"processed"`
+	Valid         int `json:"valid"`
+	Changed       int `json:"changed"`
+	Invalid       int `json:"invalid"`        // Files with errors
+	Unreadable    int `json:"unreadable"`     // Files that could not be checked at all, eg: invalid JSON
+	IndexProblems int `json:"index_problems"` // Warnings and errors about a root.json
+}
+
+func (s summary) String() string {
+	return fmt.Sprintf("Processed %d files: %d valid, %d changed, %d with errors, %d unreadable, %d root.json problems", s.Processed, s.Valid, s.Changed, s.Invalid, s.Unreadable, s.IndexProblems)
+}
+
+var (
+	results     []*fileResult
+	current     *fileResult   // The file currently being reported, that problems are recorded against
+	runProblems = []problem{} // Problems logged outside of any file, eg: when checking root.json
+)
+
+// newFileResult returns an empty result for file f, with a logger that records problems against it.
+func newFileResult(f string) *fileResult {
+	res := &fileResult{File: f, Problems: []problem{}, out: f}
+	res.display = slog.New(tint.NewHandler(&res.log, logOptions))
+	res.logger = slog.New(reportHandler{res.display.Handler(), res})
+	return res
+}
+
+// addFinding logs a finding of the checks, recording it as a problem if it's a warning or error. One that isn't about
+// a particular Rock-on, eg: that there's none, is logged with the file, as nothing else would say which it's about.
+func (res *fileResult) addFinding(f validator.Finding) {
+	if _, found := f.Attrs["rockon"]; found {
+		f.Log(res.display)
+	} else {
+		f.Log(res.display.With(slog.String("file", res.File)))
+	}
+	if f.Severity >= validator.SeverityWarning {
+		res.Problems = append(res.Problems, problemOf(f))
+	}
+}
+
+// reportFindings logs the findings of the checks made by the command itself, eg: of a root.json, to stderr, recording
+// any warnings and errors against res, or the run as a whole if it's nil. It returns the number of errors.
+func reportFindings(res *fileResult, findings []validator.Finding) (errs int) {
+	for _, f := range findings {
+		f.Log(display)
+		if f.Severity == validator.SeverityError {
+			errs++
+		}
+		switch {
+		case f.Severity < validator.SeverityWarning:
+		case res != nil:
+			res.Problems = append(res.Problems, problemOf(f))
+		default:
+			runProblems = append(runProblems, problemOf(f))
+		}
+	}
+	return errs
+}
+
+// problemOf returns the finding f as a problem, to report.
+func problemOf(f validator.Finding) problem {
+	p := problem{Level: f.Severity.Level().String(), Rule: f.Rule, Path: f.Path, Message: f.Message, Attrs: map[string]any{}}
+	for k, v := range f.Attrs {
+		if err, ok := v.(error); ok {
+			v = err.Error()
+		}
+		p.Attrs[k] = v
+	}
+	return p
+}
+
+// printReport writes the results to stdout, if a machine-readable --format was asked for, followed by a summary of
+// them to stderr.
+func printReport() {
+	current = nil
+	sum := summarize()
+
+	switch formatFlag {
+	case formatJSON:
+		out, _ := json.MarshalIndent(map[string]any{"summary": sum, "files": checkedResults(), "problems": runProblems}, "", "    ")
+		fmt.Println(string(out))
+	case formatGitHub:
+		for _, res := range results {
+			printAnnotations(res)
+		}
+		printAnnotations(&fileResult{Problems: runProblems})
+	case formatSARIF:
+		printSARIF()
+	case formatJUnit:
+		printJUnit(sum)
+	}
+
+	if !quietFlag {
+		fmt.Fprintln(os.Stderr, sum)
+	}
+}
+
+// summarize counts the results, setting whether each is valid.
+func summarize() (sum summary) {
+	for _, p := range runProblems {
+		if p.isIndex() {
+			sum.IndexProblems++
+		}
+	}
+	for _, res := range results {
+		for _, p := range res.Problems {
+			if p.isIndex() {
+				sum.IndexProblems++
+			}
+		}
+		if res.skipped {
+			continue // Not a rockon, so neither processed nor valid
+		}
+		hasErrors := res.errorCount() > 0
+		res.Valid = !res.Changed && !hasErrors
+
+		sum.Processed++
+		if res.Valid {
+			sum.Valid++
+		}
+		if res.Changed {
+			sum.Changed++
+		}
+		if res.exitCode != exitOK {
+			sum.Unreadable++
+		} else if hasErrors {
+			sum.Invalid++
+		}
+	}
+	return sum
+}
+
+// checkedResults returns the results of the files that were checked, leaving out those skipped as not rockons, eg: a
+// root.json, which the summary doesn't count either.
+func checkedResults() []*fileResult {
+	checked := []*fileResult{}
+	for _, res := range results {
+		if !res.skipped {
+			checked = append(checked, res)
+		}
+	}
+	return checked
+}
+
+// errorCount returns the number of errors logged while checking the file.
+func (res *fileResult) errorCount() (errs int) {
+	for _, p := range res.Problems {
+		if p.Level == slog.LevelError.String() {
+			errs++
+		}
+	}
+	return errs
+}
+
+// warningCount returns the number of warnings logged across all the files, and outside of any, for --fail-on-warning.
+func warningCount() (warnings int) {
+	problems := slices.Clone(runProblems)
+	for _, res := range results {
+		problems = append(problems, res.Problems...)
+	}
+	for _, p := range problems {
+		if p.Level == slog.LevelWarn.String() {
+			warnings++
+		}
+	}
+	return warnings
+}
+
+// isIndex reports whether the problem is about a root.json, rather than a rockon.
+func (p problem) isIndex() bool {
+	_, found := p.Attrs["root.json"]
+	return found
+}
+
+// printAnnotations prints the problems of res as GitHub Actions workflow commands, so that they show up inline on
+// a pull request.
+func printAnnotations(res *fileResult) {
+	if res.Changed {
+		level := "warning"
+		if checkFlag || checkFormatFlag {
+			level = "error"
+		}
+		fmt.Printf("::%s file=%s::%s\n", level, escapeProperty(res.File), "Not correctly formatted, run rockon-validator --write")
+	}
+	for _, p := range res.Problems {
+		level := "warning"
+		if p.Level == slog.LevelError.String() || failOnWarningFlag {
+			level = "error"
+		}
+		props := "file=" + escapeProperty(res.File)
+		if rootFile, ok := p.Attrs["root.json"].(string); ok {
+			props = "file=" + escapeProperty(rootFile)
+		} else if line := p.line(res.data); line > 0 {
+			props += fmt.Sprintf(",line=%d", line)
+		}
+
+		fmt.Printf("::%s %s::%s\n", level, props, escapeData(p.text()))
+	}
+}
+
+// lineAttrs are the attributes naming JSON keys, from outermost to innermost, that are searched for to find the
+// line a problem is on.
+var lineAttrs = []string{"rockon", "container", "port"}
+
+// line approximates the line of data the problem is on, by searching for each of the keys it names in turn. It
+// returns 0 if none of them could be found.
+func (p problem) line(data []byte) int {
+	offset, found := 0, false
+	for _, attr := range lineAttrs {
+		key, ok := p.Attrs[attr].(string)
+		if !ok {
+			continue
+		}
+		re := regexp.MustCompile(regexp.QuoteMeta(fmt.Sprintf("%q", key)) + `\s*:`)
+		if loc := re.FindIndex(data[offset:]); loc != nil {
+			offset += loc[0]
+			found = true
+		}
+	}
+	if !found {
+		return 0
+	}
+	return bytes.Count(data[:offset], []byte("\n")) + 1
+}
+
+func escapeData(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(s)
+}
+
+func escapeProperty(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C").Replace(s)
+}
+
+// reportHandler records any warnings and errors against its file, or if it has none the current file or the run as a
+// whole, before passing them on to the wrapped handler. Warnings are recorded even if the wrapped handler's level
+// would drop them.
+type reportHandler struct {
+	slog.Handler
+	res *fileResult
+}
+
+func (h reportHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= slog.LevelWarn || h.Handler.Enabled(ctx, level)
+}
+
+func (h reportHandler) Handle(ctx context.Context, r slog.Record) error {
+	// The rule, if the problem was found by a check, is recorded but not logged, as for the findings of the validator
+	ruleID := ruleInternal
+	logged := slog.NewRecord(r.Time, r.Level, r.Message, r.PC)
+	r.Attrs(func(attr slog.Attr) bool {
+		if attr.Key == "rule" {
+			ruleID = attr.Value.String()
+		} else {
+			logged.AddAttrs(attr)
+		}
+		return true
+	})
+	r = logged
+
+	if r.Level >= slog.LevelWarn {
+		p := problem{Level: r.Level.String(), Rule: ruleID, Message: r.Message, Attrs: map[string]any{}}
+		r.Attrs(func(attr slog.Attr) bool {
+			v := attr.Value.Resolve().Any()
+			if err, ok := v.(error); ok {
+				v = err.Error()
+			}
+			p.Attrs[attr.Key] = v
+			return true
+		})
+		if h.res != nil {
+			h.res.Problems = append(h.res.Problems, p)
+		} else if current != nil {
+			current.Problems = append(current.Problems, p)
+		} else {
+			runProblems = append(runProblems, p)
+		}
+	}
+	if !h.Handler.Enabled(ctx, r.Level) {
+		return nil
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h reportHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return reportHandler{h.Handler.WithAttrs(attrs), h.res}
+}
+
+func (h reportHandler) WithGroup(name string) slog.Handler {
+	return reportHandler{h.Handler.WithGroup(name), h.res}
+}
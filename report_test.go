@@ -0,0 +1,79 @@
+// SPDX-License-Identifier: GPL-3.0-or-later
+package main
+
+import (
+	"io"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/exp/slog" // nee "log/slog"
+)
+
+func TestSummarize(t *testing.T) {
+	indexProblem := problem{Level: slog.LevelError.String(), Message: "Rock-on is missing from root.json", Attrs: map[string]any{"root.json": "root.json"}}
+	results = []*fileResult{
+		{File: "plex.json"},
+		{File: "wsdd.json", Changed: true},
+		{File: "root.json", skipped: true},
+		{File: "notes.txt", skipped: true},
+	}
+	runProblems = []problem{indexProblem}
+	t.Cleanup(func() { results, runProblems = nil, nil })
+
+	want := summary{Processed: 2, Valid: 1, Changed: 1, IndexProblems: 1}
+	if sum := summarize(); sum != want {
+		t.Errorf("summarize() = %+v, want %+v", sum, want)
+	}
+}
+
+func TestReportLeavesOutSkipped(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"plex.json": plexJSON, "root.json": `{"Plex": "plex.json"}` + "\n"})
+	results = []*fileResult{checkFile(filepath.Join(dir, "plex.json")), checkFile(filepath.Join(dir, "root.json"))}
+	t.Cleanup(func() { results = nil })
+	if !results[1].skipped {
+		t.Fatalf("checkFile(root.json) skipped = false, want true")
+	}
+
+	sum := summarize()
+	if checked := checkedResults(); len(checked) != 1 || checked[0] != results[0] {
+		t.Errorf("checkedResults() = %d files, want only plex.json", len(checked))
+	}
+	suite := junitSuite(sum)
+	if suite.Tests != 1 || suite.Failures != 0 || len(suite.TestCases) != 1 {
+		t.Fatalf("junitSuite() = %d tests, %d failures, %d test cases, want 1, 0, 1", suite.Tests, suite.Failures, len(suite.TestCases))
+	}
+	if tc := suite.TestCases[0]; tc.Name != results[0].File || tc.Failure != nil {
+		t.Errorf("junitSuite() test case = %+v, want plex.json passing", tc)
+	}
+}
+
+func TestWarningCountSkipsRootJSON(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"plex.json": plexJSON, "root.json": `{"Plex": "plex.json"}` + "\n"})
+	for _, f := range []string{"plex.json", "root.json"} { // As from dir/*
+		results = append(results, checkFile(filepath.Join(dir, f)))
+	}
+	t.Cleanup(func() { results = nil })
+	if warnings := warningCount(); warnings != 0 {
+		t.Errorf("warningCount() = %d, want 0, as skipping root.json is not a warning", warnings)
+	}
+}
+
+func TestReportHandlerRule(t *testing.T) {
+	res := newFileResult("plex.json")
+	l := slog.New(reportHandler{slog.NewTextHandler(io.Discard, nil), res})
+	l.Warn("File starts with a UTF-8 byte order mark, which --write removes", slog.String("file", "plex.json"), rule(ruleByteOrderMark))
+	l.Error("Writing rockon", slog.String("file", "plex.json"))
+
+	want := []string{ruleByteOrderMark, ruleInternal}
+	if len(res.Problems) != len(want) {
+		t.Fatalf("Problems = %+v, want %d", res.Problems, len(want))
+	}
+	for i, p := range res.Problems {
+		if p.Rule != want[i] {
+			t.Errorf("Problems[%d].Rule = %q, want %q", i, p.Rule, want[i])
+		}
+		if _, found := p.Attrs["rule"]; found {
+			t.Errorf("Problems[%d] has the rule as an attribute", i)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+// SPDX-License-Identifier: GPL-3.0-or-later
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+
+	"golang.org/x/exp/slog" // nee "log/slog"
+)
+
+const (
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+	sarifVersion = "2.1.0"
+	toolURI      = "https://github.com/rockstor/rockon-validator"
+	formatRule   = "rockon/format" // The file is not correctly formatted
+)
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion          `json:"region,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine int `json:"startLine"`
+}
+
+// text is the problem's message followed by its attributes, for formats that only take a single line of text.
+func (p problem) text() string {
+	msg := p.Message
+	for _, k := range sortedKeys(p.Attrs) {
+		msg += fmt.Sprintf(" %s=%v", k, p.Attrs[k])
+	}
+	return msg
+}
+
+// printSARIF prints the results as a SARIF log, for GitHub code scanning and the like.
+func printSARIF() {
+	driver := sarifDriver{Name: "rockon-validator", InformationURI: toolURI, Rules: []sarifRule{}}
+	seen := map[string]bool{}
+	addRule := func(id, description string) {
+		if !seen[id] {
+			seen[id] = true
+			driver.Rules = append(driver.Rules, sarifRule{ID: id, ShortDescription: sarifMessage{description}})
+		}
+	}
+
+	sarifResults := []sarifResult{}
+	add := func(res *fileResult) {
+		if res.Changed {
+			addRule(formatRule, "Not correctly formatted")
+			level := "warning"
+			if checkFlag || checkFormatFlag {
+				level = "error"
+			}
+			sarifResults = append(sarifResults, sarifResult{
+				RuleID:    formatRule,
+				Level:     level,
+				Message:   sarifMessage{"Not correctly formatted, run rockon-validator --write"},
+				Locations: []sarifLocation{sarifLocationOf(res.File, 0)},
+			})
+		}
+		for _, p := range res.Problems {
+			addRule(p.Rule, p.Message)
+			level := "warning"
+			if p.Level == slog.LevelError.String() {
+				level = "error"
+			}
+			loc := sarifLocationOf(res.File, p.line(res.data))
+			if rootFile, ok := p.Attrs["root.json"].(string); ok {
+				loc = sarifLocationOf(rootFile, 0)
+			}
+			sarifResults = append(sarifResults, sarifResult{
+				RuleID:    p.Rule,
+				Level:     level,
+				Message:   sarifMessage{p.text()},
+				Locations: []sarifLocation{loc},
+			})
+		}
+	}
+	for _, res := range results {
+		add(res)
+	}
+	add(&fileResult{Problems: runProblems})
+
+	out, _ := json.MarshalIndent(sarifLog{
+		Schema:  sarifSchema,
+		Version: sarifVersion,
+		Runs:    []sarifRun{{Tool: sarifTool{driver}, Results: sarifResults}},
+	}, "", "    ")
+	fmt.Println(string(out))
+}
+
+func sarifLocationOf(f string, line int) sarifLocation {
+	loc := sarifLocation{sarifPhysicalLocation{ArtifactLocation: sarifArtifactLocation{filepath.ToSlash(f)}}}
+	if line > 0 {
+		loc.PhysicalLocation.Region = &sarifRegion{StartLine: line}
+	}
+	return loc
+}
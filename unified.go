@@ -0,0 +1,116 @@
+// SPDX-License-Identifier: BSD-3-Clause
+//
+// Copyright 2019 The Go Authors. All rights reserved.
+//
+// toUnified, splitLines and addEqualLines are adapted from unified.go of github.com/hexops/gotextdiff v1.0.3, itself
+// from golang.org/x/tools/internal/lsp/diff. Their use is governed by its license:
+//
+// Copyright (c) 2009 The Go Authors. All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//    * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//    * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//    * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package main
+
+import (
+	"strings"
+
+	"github.com/hexops/gotextdiff"
+)
+
+// toUnified is gotextdiff.ToUnified, which always has 3 lines of context, with the number of lines as a parameter.
+func toUnified(from, to, content string, edits []gotextdiff.TextEdit, context int) gotextdiff.Unified {
+	u := gotextdiff.Unified{From: from, To: to}
+	if len(edits) == 0 {
+		return u
+	}
+	edits = gotextdiff.LineEdits(content, edits)
+	lines := splitLines(content)
+	var h *gotextdiff.Hunk
+	last := 0
+	toLine := 0
+	for _, edit := range edits {
+		start := edit.Span.Start().Line() - 1
+		end := edit.Span.End().Line() - 1
+		switch {
+		case h != nil && start == last:
+			// Direct extension
+		case h != nil && start <= last+2*context:
+			// Within range of the previous lines, so add the joiners
+			addEqualLines(h, lines, last, start)
+		default:
+			// Start a new hunk
+			if h != nil {
+				addEqualLines(h, lines, last, last+context)
+				u.Hunks = append(u.Hunks, h)
+			}
+			toLine += start - last
+			h = &gotextdiff.Hunk{FromLine: start + 1, ToLine: toLine + 1}
+			delta := addEqualLines(h, lines, start-context, start)
+			h.FromLine -= delta
+			h.ToLine -= delta
+		}
+		last = start
+		for i := start; i < end; i++ {
+			h.Lines = append(h.Lines, gotextdiff.Line{Kind: gotextdiff.Delete, Content: lines[i]})
+			last++
+		}
+		if edit.NewText != "" {
+			for _, line := range splitLines(edit.NewText) {
+				h.Lines = append(h.Lines, gotextdiff.Line{Kind: gotextdiff.Insert, Content: line})
+				toLine++
+			}
+		}
+	}
+	if h != nil {
+		addEqualLines(h, lines, last, last+context)
+		u.Hunks = append(u.Hunks, h)
+	}
+	return u
+}
+
+func splitLines(text string) []string {
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+func addEqualLines(h *gotextdiff.Hunk, lines []string, start, end int) int {
+	delta := 0
+	for i := start; i < end; i++ {
+		if i < 0 {
+			continue
+		}
+		if i >= len(lines) {
+			return delta
+		}
+		h.Lines = append(h.Lines, gotextdiff.Line{Kind: gotextdiff.Equal, Content: lines[i]})
+		delta++
+	}
+	return delta
+}
@@ -0,0 +1,894 @@
+// SPDX-License-Identifier: GPL-3.0-or-later
+package validator
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/exp/maps"
+	"golang.org/x/exp/slices"
+	"golang.org/x/exp/slog" // nee "log/slog"
+
+	"github.com/rockstor/rockon-validator/model"
+)
+
+// sortedKeys returns the keys of m in sorted order, so that checks report in a stable order.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := maps.Keys(m)
+	slices.Sort(keys)
+	return keys
+}
+
+const maxPort = 65535
+
+// Bounds, in KB, outside of which a volume's min_size was likely entered in the wrong unit.
+const (
+	minSizeLow  = 1024                    // 1 MB
+	minSizeHigh = 10 * 1024 * 1024 * 1024 // 10 TB
+)
+
+// A checker runs the semantic checks, logging the problems it finds to its logger. Each file being checked has its
+// own, so that files can be checked concurrently without their problems being mixed up.
+type checker struct {
+	logger *slog.Logger
+	opts   Options
+}
+
+// warnOrError logs msg as an error if asError is set, otherwise as a warning, returning the number of errors logged.
+func (chk checker) warnOrError(asError bool, msg string, args ...any) int {
+	if asError {
+		chk.logger.Error(msg, args...)
+		return 1
+	}
+	chk.logger.Warn(msg, args...)
+	return 0
+}
+
+// checkRockOn runs all the semantic checks over each Rock-on in the file and returns the number of errors found.
+func (chk checker) checkRockOn(rockon model.RockOn) (errs int) {
+	switch {
+	case len(rockon) == 0:
+		chk.logger.Error("No Rock-on in the file, expected a single entry", rule(RuleNoRockOn))
+		errs++
+	case len(rockon) > 1:
+		chk.logger.Error("More than one Rock-on in the file, expected a single entry", slog.Any("rockons", sortedKeys(rockon)), rule(RuleMultipleRockOns))
+		errs++
+	}
+	for _, name := range sortedKeys(rockon) {
+		details := rockon[name]
+		errs += chk.checkName(name)
+		errs += chk.checkProtocols(name, details)
+		errs += chk.checkPortNumbers(name, details)
+		errs += chk.checkHostPorts(name, details)
+		chk.checkPortProtocolOverlap(name, details)
+		chk.checkLaunchOrder(name, details)
+		chk.fixImageTags(name, details)
+		errs += chk.checkImages(name, details)
+		chk.checkDuplicateImages(name, details)
+		errs += chk.checkURLs(name, details)
+		chk.checkVersion(name, details)
+		chk.checkVolumeAddSupport(name, details)
+		errs += chk.checkContainerLinks(name, details)
+		chk.checkOpts(name, details)
+		chk.checkCmdArguments(name, details)
+		chk.checkPaths(name, details)
+		chk.checkNestedVolumes(name, details)
+		chk.checkEnvironmentIndices(name, details)
+		chk.checkEnvironmentNames(name, details)
+		chk.checkDeviceIndices(name, details)
+		chk.checkUISlug(name, details)
+		chk.checkUIPort(name, details)
+		chk.checkUIHttps(name, details)
+		if chk.opts.StrictSizes {
+			chk.checkVolumeSizes(name, details)
+		}
+		if chk.opts.StrictMetadata {
+			chk.checkMetadata(name, details)
+		}
+		if chk.opts.CheckHTML {
+			chk.checkMoreInfo(name, details)
+		}
+		if chk.opts.CustomConfigKeys != nil {
+			chk.checkCustomConfig(name, details)
+		}
+		if chk.opts.CheckURLs {
+			errs += chk.checkReachable(name, details)
+		}
+	}
+	return errs
+}
+
+// checkName ensures the Rock-on's name, shown in the UI and used in root.json, is not empty, and warns if it has
+// leading or trailing whitespace, or control characters.
+func (chk checker) checkName(name string) (errs int) {
+	switch {
+	case strings.TrimSpace(name) == "":
+		chk.logger.Error("Rock-on name is empty", slog.String("rockon", name), rule(RuleEmptyName))
+		errs++
+	case strings.TrimSpace(name) != name:
+		chk.logger.Warn("Rock-on name has leading or trailing whitespace", slog.String("rockon", name), rule(RuleNameWhitespace))
+	}
+	if strings.IndexFunc(name, unicode.IsControl) >= 0 {
+		chk.logger.Warn("Rock-on name contains control characters", slog.String("rockon", name), rule(RuleNameControlChars))
+	}
+	return errs
+}
+
+// checkProtocols ensures every port protocol is one that docker understands.
+func (chk checker) checkProtocols(name string, details model.RockonDetails) (errs int) {
+	for _, cName := range sortedKeys(details.Containers) {
+		ports := details.Containers[cName].Ports
+		for _, port := range sortedKeys(ports) {
+			if protocol := ports[port].Protocol; !protocol.Valid() {
+				chk.logger.Error("Invalid port protocol, expected tcp, udp or empty", slog.String("rockon", name), slog.String("container", cName), slog.String("port", port), slog.String("protocol", string(protocol)), rule(RuleInvalidProtocol))
+				errs++
+			}
+		}
+	}
+	return errs
+}
+
+// checkPortNumbers ensures every container port, and host_default, is a port number from 1 to 65535.
+func (chk checker) checkPortNumbers(name string, details model.RockonDetails) (errs int) {
+	for _, cName := range sortedKeys(details.Containers) {
+		ports := details.Containers[cName].Ports
+		for _, port := range sortedKeys(ports) {
+			if n, err := strconv.ParseUint(port, 10, 16); err != nil || n == 0 {
+				chk.logger.Error("Invalid container port, expected 1 to 65535", slog.String("rockon", name), slog.String("container", cName), slog.String("port", port), rule(RuleInvalidPort))
+				errs++
+			}
+			if hostPort := ports[port].HostDefault; hostPort == 0 || hostPort > maxPort {
+				chk.logger.Error("Invalid host_default port, expected 1 to 65535", slog.String("rockon", name), slog.String("container", cName), slog.String("port", port), slog.Uint64("host_default", uint64(hostPort)), rule(RuleInvalidHostPort))
+				errs++
+			}
+		}
+	}
+	return errs
+}
+
+// checkHostPorts ensures no two ports share the same host_default for the same protocol, as the Rock-on could then
+// never start. A port without a protocol is mapped for both, so collides with either.
+func (chk checker) checkHostPorts(name string, details model.RockonDetails) (errs int) {
+	type mapping struct {
+		port     string
+		protocol model.Protocol
+	}
+	seen := map[model.UintValue][]mapping{}
+	for _, cName := range sortedKeys(details.Containers) {
+		ports := details.Containers[cName].Ports
+		for _, port := range sortedKeys(ports) {
+			hostPort := ports[port].HostDefault
+			if hostPort == 0 {
+				continue // Unset, so nothing to collide with
+			}
+			current := mapping{cName + ":" + port, ports[port].Protocol}
+			for _, prev := range seen[hostPort] {
+				if prev.protocol == "" || current.protocol == "" || prev.protocol == current.protocol {
+					errs += chk.warnOrError(!chk.opts.WarnDuplicatePorts, "Duplicate host_default port", slog.String("rockon", name), slog.Uint64("host_default", uint64(hostPort)), slog.String("first", prev.port), slog.String("second", current.port), rule(RuleDuplicateHostPort))
+					break
+				}
+			}
+			seen[hostPort] = append(seen[hostPort], current)
+		}
+	}
+	return errs
+}
+
+// checkPortProtocolOverlap warns when a container maps the same port number more than once for the same protocol, eg:
+// "53" for both tcp and udp alongside "053" for udp. The keys differ, so the duplicate isn't collapsed when parsed.
+func (chk checker) checkPortProtocolOverlap(name string, details model.RockonDetails) {
+	for _, cName := range sortedKeys(details.Containers) {
+		ports := details.Containers[cName].Ports
+		byNumber := map[uint64][]string{}
+		for _, port := range sortedKeys(ports) {
+			number, _, _ := strings.Cut(port, "/")
+			if n, err := strconv.ParseUint(number, 10, 16); err == nil {
+				byNumber[n] = append(byNumber[n], port)
+			}
+		}
+
+		numbers := maps.Keys(byNumber)
+		slices.Sort(numbers)
+		for _, n := range numbers {
+			keys := byNumber[n]
+			for i, first := range keys {
+				for _, second := range keys[i+1:] {
+					p1, p2 := ports[first].Protocol, ports[second].Protocol
+					if p1 == "" || p2 == "" || p1 == p2 {
+						chk.logger.Warn("Container port is mapped more than once for the same protocol", slog.String("rockon", name), slog.String("container", cName), slog.String("first", first), slog.String("first_protocol", string(p1)), slog.String("second", second), slog.String("second_protocol", string(p2)), rule(RulePortProtocolOverlap))
+					}
+				}
+			}
+		}
+	}
+}
+
+// checkLaunchOrder warns when the containers' launch_order values are not a simple 1, 2, 3... sequence. A single
+// container's missing launch_order can only be 1, so it's set to that.
+func (chk checker) checkLaunchOrder(name string, details model.RockonDetails) {
+	byOrder := map[model.UintValue][]string{}
+	for _, cName := range sortedKeys(details.Containers) {
+		c := details.Containers[cName]
+		order := c.LaunchOrder
+		if order == 0 && len(details.Containers) == 1 {
+			chk.logger.Warn("Launch order is 0, setting it to 1", slog.String("rockon", name), slog.String("container", cName), rule(RuleLaunchOrderZero))
+			c.LaunchOrder = 1
+			details.Containers[cName] = c
+			continue
+		}
+		if order == 0 {
+			chk.logger.Warn("Launch order is 0, likely missing", slog.String("rockon", name), slog.String("container", cName), rule(RuleLaunchOrderZero))
+			continue
+		}
+		byOrder[order] = append(byOrder[order], cName)
+	}
+
+	orders := maps.Keys(byOrder)
+	slices.Sort(orders)
+	for i, order := range orders {
+		if containers := byOrder[order]; len(containers) > 1 {
+			chk.logger.Warn("Duplicate launch order", slog.String("rockon", name), slog.Uint64("launch_order", uint64(order)), slog.Any("containers", containers), rule(RuleDuplicateLaunchOrder))
+		}
+		if expected := model.UintValue(i + 1); order != expected {
+			chk.logger.Warn("Gap in launch order", slog.String("rockon", name), slog.Uint64("launch_order", uint64(order)), slog.Uint64("expected", uint64(expected)), slog.Any("containers", byOrder[order]), rule(RuleLaunchOrderGap))
+		}
+	}
+}
+
+// checkImages ensures each container's image looks like a docker image reference, eg: linuxserver/plex or
+// ghcr.io/foo/bar. Any tag belongs in the container's tag instead, which should be the tag alone, eg: 1.2.3.
+func (chk checker) checkImages(name string, details model.RockonDetails) (errs int) {
+	for _, cName := range sortedKeys(details.Containers) {
+		image, tag := details.Containers[cName].Image, details.Containers[cName].Tag
+		if strings.ContainsAny(tag, "/: \t\n") && !strings.Contains(tag, "sha256:") { // A digest is warned about by fixImageTags
+			errs += chk.warnOrError(chk.opts.StrictImages, "Tag contains a repository or whitespace, expected the tag alone", slog.String("rockon", name), slog.String("container", cName), slog.String("tag", tag), rule(RuleInvalidTag))
+		}
+
+		attrs := []any{slog.String("rockon", name), slog.String("container", cName), slog.String("image", image)}
+		if image == "" {
+			errs += chk.warnOrError(chk.opts.StrictImages, "Image is empty", append(attrs, rule(RuleEmptyImage))...)
+			continue
+		}
+		if strings.HasPrefix(image, "/") || strings.HasSuffix(image, "/") {
+			errs += chk.warnOrError(chk.opts.StrictImages, "Image has a leading or trailing slash", append(attrs, rule(RuleInvalidImage))...)
+		}
+
+		path := imagePath(image)
+		if strings.ContainsAny(path, ":@") {
+			errs += chk.warnOrError(chk.opts.StrictImages, "Image contains a tag or digest, which belongs in tag", append(attrs, rule(RuleTagInImage))...)
+			path, _, _ = strings.Cut(path, "@")
+			path, _, _ = strings.Cut(path, ":")
+		}
+		if path != strings.ToLower(path) {
+			errs += chk.warnOrError(chk.opts.StrictImages, "Image repository contains uppercase characters", append(attrs, rule(RuleInvalidImage))...)
+		}
+	}
+	return errs
+}
+
+// imagePath strips the registry, if any, from image. The registry may contain a port, and so a colon, which would
+// otherwise be mistaken for a tag. eg: localhost:5000/foo/bar
+func imagePath(image string) string {
+	if registry, rest, found := strings.Cut(image, "/"); found && (strings.ContainsAny(registry, ".:") || registry == "localhost") {
+		return rest
+	}
+	return image
+}
+
+// checkDuplicateImages notes when containers share the same image and tag, which is more often a copy and paste
+// mistake than a deliberate second instance.
+func (chk checker) checkDuplicateImages(name string, details model.RockonDetails) {
+	byImage := map[string][]string{}
+	for _, cName := range sortedKeys(details.Containers) {
+		c := details.Containers[cName]
+		if c.Image == "" {
+			continue // Already warned about
+		}
+		tag := c.Tag
+		if tag == "" {
+			tag = "latest" // As docker defaults to
+		}
+		byImage[c.Image+":"+tag] = append(byImage[c.Image+":"+tag], cName)
+	}
+	for _, image := range sortedKeys(byImage) {
+		if containers := byImage[image]; len(containers) > 1 {
+			chk.logger.Info("Containers share the same image", slog.String("rockon", name), slog.String("image", image), slog.Any("containers", containers), rule(RuleDuplicateImage))
+		}
+	}
+}
+
+// fixImageTags moves any tag inlined in a container's image, eg: linuxserver/plex:latest, into its tag, provided
+// that doesn't conflict with a tag that is already set. Digests are left alone, as they can't be expressed as a tag.
+func (chk checker) fixImageTags(name string, details model.RockonDetails) {
+	for _, cName := range sortedKeys(details.Containers) {
+		c := details.Containers[cName]
+		attrs := []any{slog.String("rockon", name), slog.String("container", cName), slog.String("image", c.Image), slog.String("tag", c.Tag)}
+		if strings.Contains(c.Tag, "sha256:") {
+			chk.logger.Warn("Tag contains a digest", append(attrs, rule(RuleImageDigest))...)
+		}
+
+		path := imagePath(c.Image)
+		if strings.Contains(path, "@") {
+			if c.Tag != "" {
+				chk.logger.Warn("Image contains a digest, but tag is also set", append(attrs, rule(RuleImageDigest))...)
+			}
+			continue
+		}
+		i := strings.LastIndex(path, ":")
+		if i < 0 {
+			continue
+		}
+		repo, tag := strings.TrimSuffix(c.Image, path[i:]), path[i+1:]
+		switch c.Tag {
+		case "":
+			chk.logger.Warn("Moving tag out of image and into tag", append(attrs, rule(RuleTagInImage))...)
+		case tag:
+			chk.logger.Warn("Image duplicates tag, removing it from image", append(attrs, rule(RuleTagInImage))...)
+		default:
+			chk.logger.Warn("Image contains a tag, that conflicts with tag", append(attrs, rule(RuleTagInImage))...)
+			continue
+		}
+		c.Image, c.Tag = repo, tag
+		details.Containers[cName] = c
+	}
+}
+
+// checkURLs ensures the website and icon are absolute http(s) URLs. The icon is optional, but the website is needed
+// in practice.
+func (chk checker) checkURLs(name string, details model.RockonDetails) (errs int) {
+	if details.Website == "" {
+		errs += chk.warnOrError(chk.opts.StrictURLs, "Website is empty", slog.String("rockon", name), rule(RuleEmptyWebsite))
+	} else if !validURL(details.Website) {
+		errs += chk.warnOrError(chk.opts.StrictURLs, "Website is not an absolute http(s) URL", slog.String("rockon", name), slog.String("website", details.Website), rule(RuleInvalidURL))
+	}
+	if details.Icon != "" && !validURL(details.Icon) {
+		errs += chk.warnOrError(chk.opts.StrictURLs, "Icon is not an absolute http(s) URL", slog.String("rockon", name), slog.String("icon", details.Icon), rule(RuleInvalidURL))
+	}
+	return errs
+}
+
+// checkVersion warns when the version is empty, and notes when it's "latest" or "0", which say nothing of the version.
+func (chk checker) checkVersion(name string, details model.RockonDetails) {
+	switch strings.TrimSpace(details.Version) {
+	case "":
+		chk.logger.Warn("Version is empty", slog.String("rockon", name), rule(RuleEmptyVersion))
+	case "latest", "0":
+		chk.logger.Info("Version is not meaningful, consider the app's version instead", slog.String("rockon", name), slog.String("version", details.Version), rule(RuleMeaninglessVersion))
+	}
+}
+
+// checkVolumeAddSupport notes when volume_add_support is set, but no container has any volumes, as Shares can then
+// only be added to a Rock-on that expects none.
+func (chk checker) checkVolumeAddSupport(name string, details model.RockonDetails) {
+	if !details.VolumeAddSupport {
+		return
+	}
+	for _, c := range details.Containers {
+		if len(c.Volumes) > 0 {
+			return
+		}
+	}
+	chk.logger.Info("volume_add_support is set, but no container has volumes", slog.String("rockon", name), rule(RuleUnusedVolumeAdd))
+}
+
+func validURL(s string) bool {
+	u, err := url.Parse(s)
+	if err != nil || strings.ContainsAny(s, " \t\n") {
+		return false
+	}
+	return (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// urlClient makes the requests of checkReachable, sharing its connections, with any proxy set in the environment.
+// It has no timeout of its own, as that's of the Options, so applied to each request.
+var urlClient = &http.Client{Transport: &http.Transport{Proxy: http.ProxyFromEnvironment}}
+
+// checkReachable makes a HEAD request to the website and icon, ensuring they don't 404 etc.. Any proxy set in the
+// environment is used.
+func (chk checker) checkReachable(name string, details model.RockonDetails) (errs int) {
+	timeout := chk.opts.URLTimeout
+	if timeout == 0 {
+		timeout = defaultURLTimeout
+	}
+	for _, u := range []string{details.Website, details.Icon} {
+		if !validURL(u) {
+			continue // Already reported by checkURLs
+		}
+		chk.logger.Debug("Requesting URL", slog.String("rockon", name), slog.String("url", u))
+		resp, err := request(http.MethodHead, u, timeout)
+		if err == nil && resp.StatusCode == http.StatusMethodNotAllowed {
+			resp, err = request(http.MethodGet, u, timeout) // Not every server supports HEAD
+		}
+		if err != nil {
+			errs += chk.warnOrError(chk.opts.StrictURLs, "URL is unreachable", slog.String("rockon", name), slog.String("url", u), slog.Any("err", err), rule(RuleUnreachableURL))
+			continue
+		}
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			errs += chk.warnOrError(chk.opts.StrictURLs, "URL did not respond with success", slog.String("rockon", name), slog.String("url", u), slog.Int("status", resp.StatusCode), rule(RuleUnreachableURL))
+		}
+	}
+	return errs
+}
+
+// request makes a request of method to u with urlClient, timing out after timeout. Only the response's status is
+// needed, so its body is already closed.
+func request(method, u string, timeout time.Duration) (*http.Response, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, method, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := urlClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	return resp, nil
+}
+
+// checkContainerLinks ensures each container link is between two different containers of the Rock-on.
+func (chk checker) checkContainerLinks(name string, details model.RockonDetails) (errs int) {
+	for _, cName := range sortedKeys(details.ContainerLinks) {
+		if _, found := details.Containers[cName]; !found {
+			chk.logger.Error("Container links for an unknown container", slog.String("rockon", name), slog.String("container", cName), rule(RuleInvalidLink))
+			errs++
+		}
+		for _, link := range details.ContainerLinks[cName] {
+			attrs := []any{slog.String("rockon", name), slog.String("container", cName), slog.String("link", link.Name), slog.String("source_container", link.SourceContainer)}
+			if _, found := details.Containers[link.SourceContainer]; !found {
+				chk.logger.Error("Container link source is an unknown container", append(attrs, rule(RuleInvalidLink))...)
+				errs++
+			} else if link.SourceContainer == cName {
+				chk.logger.Error("Container links to itself", append(attrs, rule(RuleInvalidLink))...)
+				errs++
+			}
+		}
+	}
+
+	// The link names are the aliases the containers know each other by, so must be unambiguous across the Rock-on
+	byName := map[string][]string{}
+	for _, cName := range sortedKeys(details.ContainerLinks) {
+		for _, link := range details.ContainerLinks[cName] {
+			byName[link.Name] = append(byName[link.Name], cName)
+		}
+	}
+	for _, linkName := range sortedKeys(byName) {
+		if containers := byName[linkName]; len(containers) > 1 {
+			chk.logger.Error("Duplicate container link name", slog.String("rockon", name), slog.String("link", linkName), slog.Any("containers", containers), rule(RuleDuplicateLinkName))
+			errs++
+		}
+		if _, found := details.Containers[linkName]; found {
+			chk.logger.Warn("Container link name is the same as a container", slog.String("rockon", name), slog.String("link", linkName), rule(RuleLinkNameContainer))
+		}
+	}
+	return errs
+}
+
+// checkOpts warns about opts that look truncated: either element empty, or an option that isn't a flag.
+func (chk checker) checkOpts(name string, details model.RockonDetails) {
+	for _, cName := range sortedKeys(details.Containers) {
+		for i, opt := range details.Containers[cName].Opts {
+			attrs := []any{slog.String("rockon", name), slog.String("container", cName), slog.Int("index", i), slog.Any("opt", opt)}
+			switch {
+			case opt[0] == "" || opt[1] == "":
+				chk.logger.Warn("Option has an empty element", append(attrs, rule(RuleInvalidOption))...)
+			case !strings.HasPrefix(opt[0], "-"):
+				chk.logger.Warn("Option does not start with -, eg: --net", append(attrs, rule(RuleInvalidOption))...)
+			}
+		}
+	}
+}
+
+// checkCmdArguments warns about cmd_arguments without an argument. Only the value may be empty.
+func (chk checker) checkCmdArguments(name string, details model.RockonDetails) {
+	for _, cName := range sortedKeys(details.Containers) {
+		for i, arg := range details.Containers[cName].CmdArguments {
+			if arg[0] == "" {
+				chk.logger.Warn("Command argument is empty", slog.String("rockon", name), slog.String("container", cName), slog.Int("index", i), slog.Any("cmd_argument", arg), rule(RuleEmptyCmdArgument))
+			}
+		}
+	}
+}
+
+// checkPaths warns about volumes that aren't mounted at an absolute path, and devices that aren't under /dev.
+func (chk checker) checkPaths(name string, details model.RockonDetails) {
+	for _, cName := range sortedKeys(details.Containers) {
+		c := details.Containers[cName]
+		for _, v := range sortedKeys(c.Volumes) {
+			if !strings.HasPrefix(v, "/") {
+				chk.logger.Warn("Volume is not an absolute path", slog.String("rockon", name), slog.String("container", cName), slog.String("volume", v), rule(RuleRelativeVolume))
+			}
+		}
+		for _, d := range sortedKeys(c.Devices) {
+			if !strings.HasPrefix(path.Clean(d), "/dev/") { // Cleaned, so /dev/../mnt/foo is caught too
+				chk.logger.Warn("Device is not a path under /dev", slog.String("rockon", name), slog.String("container", cName), slog.String("device", d), rule(RuleInvalidDevice))
+			}
+		}
+	}
+}
+
+// checkNestedVolumes warns when one of a container's volumes is mounted within another, eg: /data and /data/sub, or
+// at the same path, eg: /data and /data/, as one shadows the other.
+func (chk checker) checkNestedVolumes(name string, details model.RockonDetails) {
+	for _, cName := range sortedKeys(details.Containers) {
+		volumes := sortedKeys(details.Containers[cName].Volumes)
+		for i, outer := range volumes {
+			for _, inner := range volumes[i+1:] {
+				attrs := []any{slog.String("rockon", name), slog.String("container", cName)}
+				outerPath, innerPath := path.Clean(outer), path.Clean(inner)
+				switch {
+				case outerPath == innerPath:
+					chk.logger.Warn("Volumes are mounted at the same path", append(attrs, slog.String("first", outer), slog.String("second", inner), rule(RuleNestedVolumes))...)
+				case strings.HasPrefix(innerPath, strings.TrimSuffix(outerPath, "/")+"/"):
+					chk.logger.Warn("Volume is mounted within another", append(attrs, slog.String("volume", inner), slog.String("within", outer), rule(RuleNestedVolumes))...)
+				case strings.HasPrefix(outerPath, strings.TrimSuffix(innerPath, "/")+"/"):
+					chk.logger.Warn("Volume is mounted within another", append(attrs, slog.String("volume", outer), slog.String("within", inner), rule(RuleNestedVolumes))...)
+				}
+			}
+		}
+	}
+}
+
+// checkEnvironmentIndices warns about environment variables whose index would order them unpredictably in the UI.
+func (chk checker) checkEnvironmentIndices(name string, details model.RockonDetails) {
+	for _, cName := range sortedKeys(details.Containers) {
+		indices := map[string]model.UintValue{}
+		for k, env := range details.Containers[cName].Environment {
+			indices[k] = env.Index
+		}
+		chk.checkIndices(name, cName, "environment", indices)
+	}
+}
+
+var envName = regexp.MustCompile(`^[A-Z_][A-Z0-9_]*$`)
+
+// checkEnvironmentNames warns when an environment variable's name is not the usual uppercase, digits and
+// underscores, eg: my-var, which some shells can't set. Some apps do expect unusual names, so it's only a warning.
+func (chk checker) checkEnvironmentNames(name string, details model.RockonDetails) {
+	for _, cName := range sortedKeys(details.Containers) {
+		for _, env := range sortedKeys(details.Containers[cName].Environment) {
+			if !envName.MatchString(env) {
+				chk.logger.Warn("Environment variable name is not uppercase letters, digits and underscores", slog.String("rockon", name), slog.String("container", cName), slog.String("env", env), rule(RuleInvalidEnvName))
+			}
+		}
+	}
+}
+
+// checkDeviceIndices warns about devices whose index would order them unpredictably in the UI.
+func (chk checker) checkDeviceIndices(name string, details model.RockonDetails) {
+	for _, cName := range sortedKeys(details.Containers) {
+		indices := map[string]model.UintValue{}
+		for k, device := range details.Containers[cName].Devices {
+			indices[k] = device.Index
+		}
+		chk.checkIndices(name, cName, "devices", indices)
+	}
+}
+
+// checkIndices warns when any of the non-zero indices are duplicated, or when only some of them are set.
+func (chk checker) checkIndices(name, cName, kind string, indices map[string]model.UintValue) {
+	byIndex := map[model.UintValue][]string{}
+	unset := []string{}
+	for _, k := range sortedKeys(indices) {
+		if indices[k] == 0 {
+			unset = append(unset, k)
+			continue
+		}
+		byIndex[indices[k]] = append(byIndex[indices[k]], k)
+	}
+
+	order := maps.Keys(byIndex)
+	slices.Sort(order)
+	for _, index := range order {
+		if len(byIndex[index]) > 1 {
+			chk.logger.Warn("Duplicate "+kind+" index", slog.String("rockon", name), slog.String("container", cName), slog.Uint64("index", uint64(index)), slog.Any(kind, byIndex[index]), rule(RuleDuplicateIndex))
+		}
+	}
+	if len(byIndex) > 0 && len(unset) > 0 {
+		chk.logger.Warn("Only some "+kind+" entries have an index", slog.String("rockon", name), slog.String("container", cName), slog.Any("unset", unset), rule(RulePartialIndex))
+	}
+}
+
+// checkVolumeSizes warns about implausible volume min_sizes, which were likely entered in MB or GB rather than KB.
+func (chk checker) checkVolumeSizes(name string, details model.RockonDetails) {
+	for _, cName := range sortedKeys(details.Containers) {
+		volumes := details.Containers[cName].Volumes
+		for _, v := range sortedKeys(volumes) {
+			size := volumes[v].MinSize
+			attrs := []any{slog.String("rockon", name), slog.String("container", cName), slog.String("volume", v), slog.Uint64("min_size", uint64(size))}
+			switch {
+			case size == 0:
+				// Unset
+			case uint64(size) < minSizeLow:
+				chk.logger.Warn("Volume min_size is implausibly small, it is in KB", append(attrs, rule(RuleImplausibleMinSize))...)
+			case uint64(size) > minSizeHigh:
+				chk.logger.Warn("Volume min_size is implausibly large, it is in KB", append(attrs, rule(RuleImplausibleMinSize))...)
+			}
+		}
+	}
+}
+
+// checkMetadata warns about empty descriptions and labels, which make for a poor UI, naming the field as a path
+// within its container, eg: ports[8080].label.
+func (chk checker) checkMetadata(name string, details model.RockonDetails) {
+	empty := func(cName, what, field string) {
+		attrs := []any{slog.String("rockon", name)}
+		if cName != "" {
+			attrs = append(attrs, slog.String("container", cName))
+		}
+		chk.logger.Warn("Empty "+what, append(attrs, slog.String("field", field), rule(RuleEmptyField))...)
+	}
+	check := func(cName, kind, key, description, label string) {
+		if description == "" {
+			empty(cName, "description", fmt.Sprintf("%s[%s].description", kind, key))
+		}
+		if label == "" {
+			empty(cName, "label", fmt.Sprintf("%s[%s].label", kind, key))
+		}
+	}
+
+	if details.Description == "" {
+		empty("", "description", "description")
+	} else {
+		chk.checkDescription(name, details)
+	}
+	for _, cName := range sortedKeys(details.Containers) {
+		c := details.Containers[cName]
+		for _, k := range sortedKeys(c.Ports) {
+			check(cName, "ports", k, c.Ports[k].Description, c.Ports[k].Label)
+		}
+		for _, k := range sortedKeys(c.Volumes) {
+			check(cName, "volumes", k, c.Volumes[k].Description, c.Volumes[k].Label)
+		}
+		for _, k := range sortedKeys(c.Environment) {
+			check(cName, "environment", k, c.Environment[k].Description, c.Environment[k].Label)
+		}
+		for _, k := range sortedKeys(c.Devices) {
+			check(cName, "devices", k, c.Devices[k].Description, c.Devices[k].Label)
+		}
+	}
+	for _, k := range sortedKeys(details.CustomConfig) {
+		check("", "custom_config", k, details.CustomConfig[k].Description, details.CustomConfig[k].Label)
+	}
+}
+
+// checkDescription warns when the Rock-on's description is only its name or version, or is too short to say much,
+// which suggest a placeholder was left in.
+func (chk checker) checkDescription(name string, details model.RockonDetails) {
+	description := strings.TrimSpace(details.Description)
+	attrs := []any{slog.String("rockon", name), slog.String("description", details.Description)}
+	switch {
+	case strings.EqualFold(description, name):
+		chk.logger.Warn("Description is the same as the name, likely a placeholder", append(attrs, rule(RulePlaceholder))...)
+	case strings.EqualFold(description, strings.TrimSpace(details.Version)):
+		chk.logger.Warn("Description is the same as the version, likely a placeholder", append(attrs, rule(RulePlaceholder))...)
+	}
+
+	minLength := chk.opts.MinDescriptionLength
+	if minLength == 0 {
+		minLength = defaultMinDescriptionLength
+	}
+	if length := utf8.RuneCountInString(description); length < minLength {
+		chk.logger.Warn("Description is short", append(attrs, slog.Int("length", length), slog.Int("min", minLength), rule(RuleShortDescription))...)
+	}
+}
+
+// voidElements are those HTML tags that are never closed, eg: <br>.
+var voidElements = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "embed": true, "hr": true, "img": true, "input": true,
+	"link": true, "meta": true, "source": true, "track": true, "wbr": true,
+}
+
+// optionalEndElements are those HTML tags whose closing tag may be left out, eg: <li>a<li>b.
+var optionalEndElements = map[string]bool{
+	"li": true, "p": true, "dt": true, "dd": true, "tr": true, "td": true, "th": true, "option": true, "thead": true,
+	"tbody": true,
+}
+
+// disallowedElements are those HTML tags that could run code, or change the page, in the Rockstor UI.
+var disallowedElements = map[string]bool{
+	"script": true, "style": true, "iframe": true, "object": true, "embed": true, "form": true, "link": true, "meta": true,
+	"base": true,
+}
+
+// checkMoreInfo warns when the more_info HTML has unclosed or mismatched tags, or anything that could run code in the
+// Rockstor UI, eg: <script>, onclick or a javascript: URL.
+func (chk checker) checkMoreInfo(name string, details model.RockonDetails) {
+	var open []string
+	handler, jsURL := false, false // eg: onclick, or href="javascript:..."
+	for _, t := range htmlTags(details.MoreInfo) {
+		closing, tag, selfClosing := t.closing, t.name, t.selfClosing
+		handler = handler || slices.ContainsFunc(t.attrs, isEventHandler)
+		jsURL = jsURL || slices.ContainsFunc(t.attrs, isJavaScriptURL)
+		if disallowedElements[tag] && !closing {
+			chk.logger.Warn("Disallowed tag in more_info", slog.String("rockon", name), slog.String("tag", tag), rule(RuleDisallowedHTMLTag))
+		}
+		switch {
+		case voidElements[tag] || selfClosing:
+		case !closing:
+			open = append(open, tag)
+		default:
+			for len(open) > 0 && open[len(open)-1] != tag && optionalEndElements[open[len(open)-1]] {
+				open = open[:len(open)-1] // Closed implicitly by its parent
+			}
+			if len(open) > 0 && open[len(open)-1] == tag {
+				open = open[:len(open)-1]
+				continue
+			}
+			chk.logger.Warn("Mismatched closing tag in more_info", slog.String("rockon", name), slog.String("tag", tag), slog.Any("open", open), rule(RuleUnbalancedHTML))
+			if i := slices.Index(open, tag); i >= 0 {
+				open = open[:i] // Recover, treating those opened since as unclosed
+			}
+		}
+	}
+	open = slices.DeleteFunc(open, func(tag string) bool { return optionalEndElements[tag] })
+	if len(open) > 0 {
+		chk.logger.Warn("Unclosed tags in more_info", slog.String("rockon", name), slog.Any("tags", open), rule(RuleUnbalancedHTML))
+	}
+	if handler {
+		chk.logger.Warn("Event handler attribute in more_info", slog.String("rockon", name), rule(RuleHTMLEventHandler))
+	}
+	if jsURL {
+		chk.logger.Warn("javascript: URL in more_info", slog.String("rockon", name), rule(RuleHTMLJavaScriptURL))
+	}
+}
+
+// checkCustomConfig warns about custom_config keys that are not among the CustomConfigKeys, as they're likely a typo,
+// or for an install handler that doesn't exist.
+func (chk checker) checkCustomConfig(name string, details model.RockonDetails) {
+	for _, key := range sortedKeys(details.CustomConfig) {
+		if !slices.Contains(chk.opts.CustomConfigKeys, key) {
+			chk.logger.Warn("Unknown custom_config key", slog.String("rockon", name), slog.String("key", key), rule(RuleUnknownCustomConfig))
+		}
+	}
+}
+
+// checkUISlug warns when the UI slug is not a clean path segment, as it's appended to the Web-UI link. Any leading or
+// trailing slashes are trimmed.
+func (chk checker) checkUISlug(name string, details model.RockonDetails) {
+	if details.UI == nil {
+		return
+	}
+	slug := details.UI.Slug
+	attrs := []any{slog.String("rockon", name), slog.String("slug", slug)}
+	if trimmed := strings.Trim(slug, "/"); trimmed != slug {
+		chk.logger.Warn("Trimming slashes from UI slug", append(attrs, rule(RuleInvalidUISlug))...)
+		details.UI.Slug = trimmed
+	}
+	if strings.ContainsAny(slug, " \t\n") {
+		chk.logger.Warn("UI slug contains whitespace", append(attrs, rule(RuleInvalidUISlug))...)
+	}
+	if strings.Contains(slug, "://") {
+		chk.logger.Warn("UI slug is a URL, rather than a path", append(attrs, rule(RuleInvalidUISlug))...)
+	}
+}
+
+// uiPorts returns the ports marked as the Web-UI, as container:port.
+func uiPorts(details model.RockonDetails) (ports []string) {
+	for _, cName := range sortedKeys(details.Containers) {
+		containerPorts := details.Containers[cName].Ports
+		for _, port := range sortedKeys(containerPorts) {
+			if containerPorts[port].UI {
+				ports = append(ports, cName+":"+port)
+			}
+		}
+	}
+	return ports
+}
+
+// checkUIPort warns when a port is marked as the Web-UI, but there's no ui.slug to link to it with, or vice versa, or
+// when more than one is, as Rockstor links to only one of them.
+func (chk checker) checkUIPort(name string, details model.RockonDetails) {
+	hasSlug := details.UI != nil && details.UI.Slug != ""
+	ports := uiPorts(details)
+	if len(ports) > 1 {
+		chk.logger.Warn("More than one port is marked as the Web-UI", slog.String("rockon", name), slog.Any("ports", ports), rule(RuleMultipleUIPorts))
+	}
+	if len(ports) > 0 && !hasSlug {
+		chk.logger.Warn("Port is marked as the Web-UI, but there is no ui.slug", slog.String("rockon", name), slog.Any("ports", ports), rule(RuleUIPortWithoutSlug))
+	}
+	if len(ports) == 0 && hasSlug {
+		chk.logger.Warn("ui.slug is set, but no port is marked as the Web-UI", slog.String("rockon", name), slog.String("slug", details.UI.Slug), rule(RuleUISlugWithoutPort))
+	}
+}
+
+// checkUIHttps warns when the Web-UI is to be reached over https, but every port marked as the Web-UI is UDP only.
+func (chk checker) checkUIHttps(name string, details model.RockonDetails) {
+	if details.UI == nil || !details.UI.Https {
+		return
+	}
+	for _, cName := range sortedKeys(details.Containers) {
+		containerPorts := details.Containers[cName].Ports
+		for _, port := range sortedKeys(containerPorts) {
+			if p := containerPorts[port]; p.UI && p.Protocol != model.UDP {
+				return // Found a TCP port
+			}
+		}
+	}
+	if ports := uiPorts(details); len(ports) > 0 {
+		chk.logger.Warn("ui.https is set, but every port marked as the Web-UI is udp", slog.String("rockon", name), slog.Any("ports", ports), rule(RuleUIHTTPSOverUDP))
+	}
+}
+
+// rawRockOn mirrors model.RockOn, but keeps the fields that model's UintValue and StrValue coerce as they were
+// written, so that checkCoercions can tell a number from a string of one.
+type rawRockOn map[string]struct {
+	Containers map[string]struct {
+		LaunchOrder json.RawMessage `json:"launch_order"`
+		Ports       map[string]struct {
+			HostDefault json.RawMessage `json:"host_default"`
+		} `json:"ports"`
+		Volumes map[string]struct {
+			MinSize json.RawMessage `json:"min_size"`
+		} `json:"volumes"`
+		Environment map[string]struct {
+			Index   json.RawMessage `json:"index"`
+			Default json.RawMessage `json:"default"`
+		} `json:"environment"`
+		Devices map[string]struct {
+			Index json.RawMessage `json:"index"`
+		} `json:"devices"`
+	} `json:"containers"`
+}
+
+// checkCoercions warns about numbers given as strings, eg: "host_default": "8080", and environment defaults given as
+// numbers, eg: "default": 1000. Both are accepted, but are written back as the right type under --write.
+func (chk checker) checkCoercions(data []byte) {
+	var rockon rawRockOn
+	if json.Unmarshal(data, &rockon) != nil {
+		return // Already parsed as a model.RockOn, so this shouldn't happen
+	}
+	for _, name := range sortedKeys(rockon) {
+		containers := rockon[name].Containers
+		for _, cName := range sortedKeys(containers) {
+			c := containers[cName]
+			quoted := func(field string, raw json.RawMessage) {
+				if len(raw) > 0 && raw[0] == '"' {
+					chk.logger.Warn("Number given as a string, it's written as a number", slog.String("rockon", name), slog.String("container", cName), slog.String("field", field), slog.String("value", string(raw)), rule(RuleNumberAsString))
+				}
+			}
+			quoted("launch_order", c.LaunchOrder)
+			for _, k := range sortedKeys(c.Ports) {
+				quoted(fmt.Sprintf("ports[%s].host_default", k), c.Ports[k].HostDefault)
+			}
+			for _, k := range sortedKeys(c.Volumes) {
+				quoted(fmt.Sprintf("volumes[%s].min_size", k), c.Volumes[k].MinSize)
+			}
+			for _, k := range sortedKeys(c.Environment) {
+				quoted(fmt.Sprintf("environment[%s].index", k), c.Environment[k].Index)
+				if raw := c.Environment[k].Default; len(raw) > 0 && raw[0] != '"' && string(raw) != "null" {
+					chk.logger.Warn("Environment default given as a number, it's written as a string", slog.String("rockon", name), slog.String("container", cName), slog.String("env", k), slog.String("value", string(raw)), rule(RuleEnvNumber))
+				}
+			}
+			for _, k := range sortedKeys(c.Devices) {
+				quoted(fmt.Sprintf("devices[%s].index", k), c.Devices[k].Index)
+			}
+		}
+	}
+}
+
+// checkUnknownFields ensures data has no fields that aren't part of a rockon, eg: a typo like "webiste", as they're
+// silently dropped when it's parsed, and so lost under --write. Only the first is found, as decoding stops there.
+func (chk checker) checkUnknownFields(data []byte) (errs int) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	var rockon model.RockOn
+	err := dec.Decode(&rockon)
+	if field, found := strings.CutPrefix(fmt.Sprint(err), "json: unknown field "); found {
+		chk.logger.Error("Unknown field, it would be dropped", slog.String("field", strings.Trim(field, `"`)), rule(RuleUnknownField))
+		errs++
+	}
+	return errs
+}
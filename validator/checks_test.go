@@ -0,0 +1,94 @@
+// SPDX-License-Identifier: GPL-3.0-or-later
+package validator
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// countFindings returns how many of the findings of res have the message msg.
+func countFindings(res Result, msg string) int {
+	n := 0
+	for _, f := range res.Findings {
+		if f.Message == msg {
+			n++
+		}
+	}
+	return n
+}
+
+func TestCheckHostPorts(t *testing.T) {
+	tests := []struct {
+		name   string
+		first  string // The protocol of the first port, a: 53
+		second string // The protocol of the second port, b: 53
+		dups   int
+	}{
+		{"tcp and udp", "tcp", "udp", 0},
+		{"both tcp", "tcp", "tcp", 1},
+		{"both udp", "udp", "udp", 1},
+		{"both unset", "", "", 1},
+		{"unset and tcp", "", "tcp", 1},
+		{"udp and unset", "udp", "", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := fmt.Sprintf(`{"DNS": {"containers": {
+				"a": {"image": "a", "launch_order": 1, "ports": {"53": {"host_default": 53, "protocol": %q}}},
+				"b": {"image": "b", "launch_order": 2, "ports": {"53": {"host_default": 53, "protocol": %q}}}
+			}}}`, tt.first, tt.second)
+			res, err := Validate([]byte(data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if dups := countFindings(res, "Duplicate host_default port"); dups != tt.dups {
+				t.Errorf("Duplicate host_default port findings = %d, want %d: %+v", dups, tt.dups, res.Findings)
+			}
+		})
+	}
+}
+
+func TestCheckReachable(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/missing":
+			w.WriteHeader(http.StatusNotFound)
+		case "/no-head":
+			if r.Method == http.MethodHead {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+			}
+		case "/slow":
+			time.Sleep(200 * time.Millisecond)
+		}
+	}))
+	defer srv.Close()
+	tests := []struct {
+		name        string
+		path        string
+		unreachable int
+		failed      int
+	}{
+		{"ok", "/", 0, 0},
+		{"not found", "/missing", 0, 1},
+		{"HEAD not allowed", "/no-head", 0, 0},
+		{"timed out", "/slow", 1, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := fmt.Sprintf(`{"Web": {"website": %q, "containers": {"web": {"image": "web", "launch_order": 1}}}}`, srv.URL+tt.path)
+			res, err := Options{CheckURLs: true, URLTimeout: 50 * time.Millisecond}.Validate([]byte(data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n := countFindings(res, "URL is unreachable"); n != tt.unreachable {
+				t.Errorf("URL is unreachable findings = %d, want %d: %+v", n, tt.unreachable, res.Findings)
+			}
+			if n := countFindings(res, "URL did not respond with success"); n != tt.failed {
+				t.Errorf("URL did not respond with success findings = %d, want %d: %+v", n, tt.failed, res.Findings)
+			}
+		})
+	}
+}
@@ -0,0 +1,57 @@
+// SPDX-License-Identifier: GPL-3.0-or-later
+package validator
+
+import (
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// An htmlTag is a start or end tag of the more_info HTML.
+type htmlTag struct {
+	name        string // Lowercased, eg: div
+	closing     bool   // An end tag, eg: </div>
+	selfClosing bool   // eg: <br/>
+	attrs       []html.Attribute
+}
+
+// htmlTags returns the tags of the HTML s, in order, as a browser would tokenize them. Comments and the contents of
+// raw text elements, eg: <script>, are skipped.
+func htmlTags(s string) (tags []htmlTag) {
+	z := html.NewTokenizer(strings.NewReader(s))
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return tags // Only ever io.EOF, reading from a string
+		case html.StartTagToken, html.EndTagToken, html.SelfClosingTagToken:
+			t := z.Token()
+			tags = append(tags, htmlTag{t.Data, t.Type == html.EndTagToken, t.Type == html.SelfClosingTagToken, t.Attr})
+		}
+	}
+}
+
+// urlAttrs are the attributes whose value is a URL, that a browser may follow.
+var urlAttrs = map[string]bool{
+	"href": true, "src": true, "action": true, "formaction": true, "xlink:href": true, "poster": true, "background": true,
+}
+
+// isEventHandler reports whether attr is an event handler, eg: onclick, which would run code.
+func isEventHandler(attr html.Attribute) bool {
+	return len(attr.Key) > 2 && strings.HasPrefix(attr.Key, "on")
+}
+
+// isJavaScriptURL reports whether attr is a javascript: URL, eg: href="javascript:alert(1)", which would run code.
+// As for a browser, any tabs and newlines in it, and leading spaces and control characters, don't hide the scheme.
+func isJavaScriptURL(attr html.Attribute) bool {
+	if !urlAttrs[attr.Key] {
+		return false
+	}
+	u := strings.Map(func(r rune) rune {
+		if r == '\t' || r == '\n' || r == '\r' {
+			return -1
+		}
+		return r
+	}, attr.Val)
+	u = strings.TrimLeft(u, "\x00\x01\x02\x03\x04\x05\x06\x07\x08\x0b\x0c\x0e\x0f\x10\x11\x12\x13\x14\x15\x16\x17\x18\x19\x1a\x1b\x1c\x1d\x1e\x1f ")
+	return strings.HasPrefix(strings.ToLower(u), "javascript:")
+}
@@ -0,0 +1,61 @@
+// SPDX-License-Identifier: GPL-3.0-or-later
+package validator
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestCheckMoreInfo(t *testing.T) {
+	tests := []struct {
+		name     string
+		moreInfo string
+		want     []string // The messages of the findings, and the tags they're about
+	}{
+		{"comment", "<!-- <div> --> Compare them: <code>if a &lt; b then</code>", nil},
+		{"a < b", "<p>if a < b then c</p>", nil},
+		{"a<b, as a browser reads it", "<p>if a<b then c</p>", []string{"Unclosed tags in more_info [b]"}},
+		{"unclosed comment", "<p>Done</p><!-- <div>", nil},
+		{"quoted >", `<img src="a>b.png"><a href='/x'>x</a>`, nil},
+		{"void and self-closing", "Line<br>Line<br/><hr />", nil},
+		{"optional end tags", "<ul><li>a<li>b</ul><p>c", nil},
+		{"script contents", "<script>if (a<b) { x = '</div>' }</script>", []string{"Disallowed tag in more_info script"}},
+		{"unclosed", "<div><b>Bold</b>", []string{"Unclosed tags in more_info [div]"}},
+		{"mismatched", "<div><span></div>", []string{"Mismatched closing tag in more_info div"}},
+		{"event handler", `<a href="#" onclick="steal()">x</a>`, []string{"Event handler attribute in more_info"}},
+		{"on in text", "Turn it on=off, <b>once</b>", nil},
+		{"event handler before a <", "<img src=x onerror=alert(1) <b>", []string{"Event handler attribute in more_info"}},
+		{"javascript: URL", `<a href="javascript:alert(1)">x</a>`, []string{"javascript: URL in more_info"}},
+		{"hidden javascript: URL", `<a href=" JaVa&#9;Script:alert(1)">x</a>`, []string{"javascript: URL in more_info"}},
+		{"javascript: in text", `<a href="https://example.com" title="javascript: a tutorial">javascript:</a>`, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			moreInfo, _ := json.Marshal(tt.moreInfo)
+			data := fmt.Sprintf(`{"Test": {"more_info": %s, "containers": {"a": {"image": "a", "launch_order": 1}}}}`, moreInfo)
+			res, err := Options{CheckHTML: true}.Validate([]byte(data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, f := range res.Findings {
+				if f.Rule != RuleDisallowedHTMLTag && f.Rule != RuleUnbalancedHTML && f.Rule != RuleHTMLEventHandler && f.Rule != RuleHTMLJavaScriptURL {
+					continue
+				}
+				msg := f.Message
+				if tag, found := f.Attrs["tag"]; found {
+					msg += fmt.Sprint(" ", tag)
+				}
+				if tags, found := f.Attrs["tags"]; found {
+					msg += fmt.Sprint(" ", tags)
+				}
+				got = append(got, msg)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("findings = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
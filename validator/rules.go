@@ -0,0 +1,76 @@
+// SPDX-License-Identifier: GPL-3.0-or-later
+package validator
+
+import "golang.org/x/exp/slog" // nee "log/slog"
+
+// The rules a Finding may have, identifying the check that made it. Unlike the message, a rule doesn't change with
+// rewording, so it can be relied on, eg: to suppress a check in a code scanning tool.
+const (
+	RuleNoRockOn        = "rockon/no-rockon"
+	RuleMultipleRockOns = "rockon/multiple-rockons"
+	RuleUnknownField    = "rockon/unknown-field"
+	RuleNumberAsString  = "rockon/number-as-string"
+	RuleEnvNumber       = "rockon/environment-default-as-number"
+
+	RuleEmptyName           = "rockon/empty-name"
+	RuleNameWhitespace      = "rockon/name-whitespace"
+	RuleNameControlChars    = "rockon/name-control-characters"
+	RuleEmptyField          = "rockon/empty-field"
+	RulePlaceholder         = "rockon/placeholder-description"
+	RuleShortDescription    = "rockon/short-description"
+	RuleEmptyWebsite        = "rockon/empty-website"
+	RuleInvalidURL          = "rockon/invalid-url"
+	RuleUnreachableURL      = "rockon/unreachable-url"
+	RuleEmptyVersion        = "rockon/empty-version"
+	RuleMeaninglessVersion  = "rockon/meaningless-version"
+	RuleUnusedVolumeAdd     = "rockon/unused-volume-add-support"
+	RuleUnknownCustomConfig = "rockon/unknown-custom-config-key"
+
+	RuleInvalidProtocol      = "rockon/invalid-port-protocol"
+	RuleInvalidPort          = "rockon/invalid-port"
+	RuleInvalidHostPort      = "rockon/invalid-host-default-port"
+	RuleDuplicateHostPort    = "rockon/duplicate-host-default-port"
+	RulePortProtocolOverlap  = "rockon/port-protocol-overlap"
+	RuleLaunchOrderZero      = "rockon/launch-order-zero"
+	RuleDuplicateLaunchOrder = "rockon/duplicate-launch-order"
+	RuleLaunchOrderGap       = "rockon/launch-order-gap"
+
+	RuleEmptyImage     = "rockon/empty-image"
+	RuleInvalidImage   = "rockon/invalid-image"
+	RuleInvalidTag     = "rockon/invalid-tag"
+	RuleTagInImage     = "rockon/tag-in-image"
+	RuleImageDigest    = "rockon/image-digest"
+	RuleDuplicateImage = "rockon/duplicate-image"
+
+	RuleInvalidLink        = "rockon/invalid-container-link"
+	RuleDuplicateLinkName  = "rockon/duplicate-container-link-name"
+	RuleLinkNameContainer  = "rockon/container-link-name-is-container"
+	RuleInvalidOption      = "rockon/invalid-option"
+	RuleEmptyCmdArgument   = "rockon/empty-cmd-argument"
+	RuleRelativeVolume     = "rockon/relative-volume"
+	RuleNestedVolumes      = "rockon/nested-volumes"
+	RuleImplausibleMinSize = "rockon/implausible-min-size"
+	RuleInvalidDevice      = "rockon/invalid-device"
+	RuleInvalidEnvName     = "rockon/invalid-environment-name"
+	RuleDuplicateIndex     = "rockon/duplicate-index"
+	RulePartialIndex       = "rockon/partial-index"
+
+	RuleDisallowedHTMLTag = "rockon/disallowed-html-tag"
+	RuleUnbalancedHTML    = "rockon/unbalanced-html-tags"
+	RuleHTMLEventHandler  = "rockon/html-event-handler"
+	RuleHTMLJavaScriptURL = "rockon/html-javascript-url"
+
+	RuleInvalidUISlug     = "rockon/invalid-ui-slug"
+	RuleMultipleUIPorts   = "rockon/multiple-ui-ports"
+	RuleUIPortWithoutSlug = "rockon/ui-port-without-slug"
+	RuleUISlugWithoutPort = "rockon/ui-slug-without-port"
+	RuleUIHTTPSOverUDP    = "rockon/ui-https-over-udp"
+)
+
+// ruleKey is the attribute the checks log the rule of a finding as, which is taken out into its Rule.
+const ruleKey = "rule"
+
+// rule returns the attribute of the rule id, for the checks to log a finding with.
+func rule(id string) slog.Attr {
+	return slog.String(ruleKey, id)
+}
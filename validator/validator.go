@@ -0,0 +1,257 @@
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+// Package validator checks and formats Rock-on definitions, as the rockon-validator command does, so that other
+// programs, eg: the Rockstor backend, can do so in-process.
+package validator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"golang.org/x/exp/slog" // nee "log/slog"
+
+	"github.com/rockstor/rockon-validator/model"
+)
+
+const (
+	defaultURLTimeout           = 5 * time.Second
+	defaultMinDescriptionLength = 20
+)
+
+// Options are the optional checks, and how strict to be. The zero value is the default of the command.
+type Options struct {
+	WarnDuplicatePorts   bool          // Only warn, rather than error, when two ports share a host_default
+	StrictImages         bool          // Error, rather than warn, when an image is not a plausible docker image reference
+	StrictURLs           bool          // Error, rather than warn, when the website or icon is not an absolute http(s) URL
+	StrictSizes          bool          // Warn when a volume min_size is implausibly small or large
+	StrictMetadata       bool          // Warn when a description or label is empty, or the description is a placeholder
+	MinDescriptionLength int           // Under StrictMetadata, warn when the Rock-on's description is shorter. Default: 20
+	CheckHTML            bool          // Warn when more_info has unclosed tags, or anything that runs code, like <script>
+	StrictFields         bool          // Error on an unknown field, eg: a typo like "webiste", which would be dropped
+	CustomConfigKeys     []string      // If set, warn about any custom_config keys not in it, as no install handler expects them
+	CheckURLs            bool          // Request the website and icon, warning if they do not respond with success
+	URLTimeout           time.Duration // Timeout for each CheckURLs request. Default: 5s
+	PreserveOrder        bool          // Keep the keys of maps in their existing order, rather than sorting them
+
+	// Logger, if set, is given any debug logging, eg: each URL requested. The findings are left to the caller, in the
+	// Result.
+	Logger *slog.Logger
+}
+
+// Severity is how serious a Finding is.
+type Severity int
+
+const (
+	SeverityInfo    Severity = iota // A note, eg: the version is "latest"
+	SeverityWarning                 // Likely a mistake, but the rockon still works
+	SeverityError                   // The rockon is broken, eg: two ports share a host_default
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityInfo:
+		return "info"
+	case SeverityWarning:
+		return "warning"
+	default:
+		return "error"
+	}
+}
+
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// Level returns the slog level to log a finding of this severity at.
+func (s Severity) Level() slog.Level {
+	switch s {
+	case SeverityInfo:
+		return slog.LevelInfo
+	case SeverityWarning:
+		return slog.LevelWarn
+	default:
+		return slog.LevelError
+	}
+}
+
+// A Finding is a note, warning or error found by the checks.
+type Finding struct {
+	Severity Severity
+	Rule     string // Identifies the check, eg: rockon/duplicate-host-default-port
+	File     string // The file the rockon was read from, if known. See ValidateFile.
+	Path     string // Where in the rockon the finding is, if anywhere in particular, eg: containers[plex].ports[32400]
+	Message  string
+	Attrs    map[string]any // eg: the rockon, container and port the finding is about
+
+	attrs []slog.Attr // Attrs, in the order they were given, for Log
+}
+
+// Log logs the finding to logger, at the level of its severity, as the checks would have.
+func (f Finding) Log(logger *slog.Logger) {
+	logger.LogAttrs(context.Background(), f.Severity.Level(), f.Message, f.attrs...)
+}
+
+// NewFinding returns a finding of the rule, for a check made outside of this package, eg: of the root.json that lists
+// the rockons.
+func NewFinding(severity Severity, rule, msg string, attrs ...slog.Attr) Finding {
+	f := Finding{Severity: severity, Rule: rule, Message: msg, Attrs: map[string]any{}}
+	for _, attr := range attrs {
+		f.Attrs[attr.Key] = attr.Value.Resolve().Any()
+		f.attrs = append(f.attrs, attr)
+	}
+	f.Path = pathOf(f.Attrs)
+	return f
+}
+
+// A Result is the outcome of validating a rockon.
+type Result struct {
+	RockOn    model.RockOn // As parsed, with any fixes applied, eg: a tag moved out of the image
+	Canonical string       // The correctly formatted rockon
+	Changed   bool         // The rockon differs from Canonical
+	Errors    int          // The number of Findings that are errors
+	Findings  []Finding
+}
+
+// A ParseError is returned by Validate when the data is not a rockon.
+type ParseError struct {
+	Err error
+}
+
+func (e *ParseError) Error() string {
+	return "parsing rockon: " + e.Err.Error()
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// Validate checks and formats the rockon in data with the default Options.
+func Validate(data []byte) (Result, error) {
+	return Options{}.Validate(data)
+}
+
+// Validate checks and formats the rockon in data. It returns a *ParseError if data is not a rockon, but otherwise
+// any problems with it are Findings, rather than an error.
+func (o Options) Validate(data []byte) (Result, error) {
+	return o.ValidateFile("", data)
+}
+
+// ValidateFile is Validate, for the rockon read from file f, which is set as the File of each Finding.
+func (o Options) ValidateFile(f string, data []byte) (Result, error) {
+	res := Result{Findings: []Finding{}}
+	if err := json.Unmarshal(data, &res.RockOn); err != nil {
+		return res, &ParseError{err}
+	}
+
+	h := findingsHandler{file: f, findings: &res.Findings}
+	if o.Logger != nil {
+		h.next = o.Logger.Handler()
+	}
+	chk := checker{slog.New(h), o}
+	res.Errors = chk.checkRockOn(res.RockOn)
+	chk.checkCoercions(data)
+	if o.StrictFields {
+		res.Errors += chk.checkUnknownFields(data)
+	}
+
+	var err error
+	if o.PreserveOrder {
+		res.Canonical, err = res.RockOn.ToJSONPreservingOrder(data)
+	} else {
+		res.Canonical, err = res.RockOn.ToJSON()
+	}
+	if err != nil {
+		return res, err
+	}
+	res.Changed = string(data) != res.Canonical
+	return res, nil
+}
+
+// findingsHandler is the collector the checks log to. It records any notes, warnings and errors as findings, and
+// passes any debug logging on to the next handler, if there is one.
+type findingsHandler struct {
+	file     string
+	findings *[]Finding
+	next     slog.Handler
+}
+
+func (h findingsHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= slog.LevelInfo || (h.next != nil && h.next.Enabled(ctx, level))
+}
+
+func (h findingsHandler) Handle(ctx context.Context, r slog.Record) error {
+	if r.Level >= slog.LevelInfo {
+		var rule string
+		var attrs []slog.Attr
+		r.Attrs(func(attr slog.Attr) bool {
+			if attr.Key == ruleKey {
+				rule = attr.Value.String() // Given by the check, rather than one of its attributes
+			} else {
+				attrs = append(attrs, attr)
+			}
+			return true
+		})
+		f := NewFinding(severityOf(r.Level), rule, r.Message, attrs...)
+		f.File = h.file
+		*h.findings = append(*h.findings, f)
+		return nil
+	}
+	if h.next == nil || !h.next.Enabled(ctx, r.Level) {
+		return nil
+	}
+	return h.next.Handle(ctx, r)
+}
+
+func severityOf(level slog.Level) Severity {
+	switch {
+	case level >= slog.LevelError:
+		return SeverityError
+	case level >= slog.LevelWarn:
+		return SeverityWarning
+	default:
+		return SeverityInfo
+	}
+}
+
+// pathAttrs are the attributes naming a key of a container, and the field of the container it's under.
+var pathAttrs = []struct{ attr, field string }{
+	{"port", "ports"},
+	{"volume", "volumes"},
+	{"env", "environment"},
+	{"device", "devices"},
+}
+
+// pathOf returns where in the rockon a finding with attrs is, eg: containers[plex].ports[32400], or "" if it's about
+// the rockon as a whole.
+func pathOf(attrs map[string]any) string {
+	var path []string
+	if cName, ok := attrs["container"].(string); ok {
+		path = append(path, fmt.Sprintf("containers[%s]", cName))
+	}
+	for _, p := range pathAttrs {
+		if key, ok := attrs[p.attr].(string); ok {
+			path = append(path, fmt.Sprintf("%s[%s]", p.field, key))
+		}
+	}
+	if field, ok := attrs["field"].(string); ok {
+		path = append(path, field)
+	}
+	return strings.Join(path, ".")
+}
+
+func (h findingsHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if h.next != nil {
+		h.next = h.next.WithAttrs(attrs)
+	}
+	return h
+}
+
+func (h findingsHandler) WithGroup(name string) slog.Handler {
+	if h.next != nil {
+		h.next = h.next.WithGroup(name)
+	}
+	return h
+}
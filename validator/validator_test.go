@@ -0,0 +1,57 @@
+// SPDX-License-Identifier: GPL-3.0-or-later
+package validator
+
+import "testing"
+
+// messyRockOn has something for many of the checks to find.
+const messyRockOn = `{" Messy": {
+	"description": "Messy",
+	"website": "example.com",
+	"version": "latest",
+	"more_info": "<div><script>",
+	"volume_add_support": true,
+	"ui": {"slug": "http://example.com/"},
+	"containers": {
+		"a": {
+			"image": "Example/a:1.0",
+			"tag": "2.0",
+			"launch_order": 0,
+			"ports": {"53": {"host_default": 53}, "70000": {"host_default": "53", "protocol": "sctp"}},
+			"volumes": {"data": {}, "/data/sub": {"min_size": 1}},
+			"devices": {"/tmp/dev": {}},
+			"environment": {"lower": {"index": 1}, "UPPER": {}},
+			"opts": [["net", "host"]],
+			"cmd_arguments": [["", ""]],
+			"container_links": [{"name": "b", "source_container": "z"}]
+		},
+		"b": {"image": "/b", "launch_order": 3}
+	}
+}}`
+
+func TestFindingRules(t *testing.T) {
+	res, err := Options{StrictMetadata: true, CheckHTML: true, StrictSizes: true}.Validate([]byte(messyRockOn))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Findings) < 20 {
+		t.Errorf("Findings = %d, want many: %+v", len(res.Findings), res.Findings)
+	}
+	for _, f := range res.Findings {
+		if f.Rule == "" {
+			t.Errorf("Finding %q has no rule", f.Message)
+		}
+		if _, found := f.Attrs[ruleKey]; found {
+			t.Errorf("Finding %q has the rule as an attribute", f.Message)
+		}
+	}
+}
+
+func TestValidateNoRockOn(t *testing.T) {
+	res, err := Validate([]byte("{}\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Errors != 1 || len(res.Findings) != 1 || res.Findings[0].Rule != RuleNoRockOn {
+		t.Errorf("Validate({}) = %d errors, findings %+v, want a single %s", res.Errors, res.Findings, RuleNoRockOn)
+	}
+}
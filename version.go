@@ -0,0 +1,38 @@
+// SPDX-License-Identifier: GPL-3.0-or-later
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+)
+
+// version is the release being built, set with -ldflags="-X main.version=v1.2.3". Without it, the module version is
+// used, as set by go install, or else "devel".
+var version string
+
+// printVersion prints the version, the commit it was built from, if known, and the Go version it was built with.
+func printVersion() {
+	v, commit := version, "unknown"
+	if info, ok := debug.ReadBuildInfo(); ok {
+		if v == "" && info.Main.Version != "(devel)" {
+			v = info.Main.Version
+		}
+		modified := false
+		for _, s := range info.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				commit = s.Value
+			case "vcs.modified":
+				modified = s.Value == "true"
+			}
+		}
+		if modified {
+			commit += "-dirty"
+		}
+	}
+	if v == "" {
+		v = "devel"
+	}
+	fmt.Printf("rockon-validator %s, commit %s, built with %s\n", v, commit, runtime.Version())
+}
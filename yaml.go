@@ -0,0 +1,20 @@
+// SPDX-License-Identifier: GPL-3.0-or-later
+package main
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// isYAML reports whether f is a rockon written in YAML, going by its extension.
+func isYAML(f string) bool {
+	ext := filepath.Ext(f)
+	return ext == ".yaml" || ext == ".yml"
+}
+
+// generatedPath returns the JSON file generated from the YAML rockon f, eg: plex.yaml -> plex.json. Only JSON is
+// understood by Rockstor, so it's the JSON that is formatted, written and listed in root.json, while the YAML is left
+// as it was written.
+func generatedPath(f string) string {
+	return strings.TrimSuffix(f, filepath.Ext(f)) + ".json"
+}